@@ -11,18 +11,16 @@ import (
 	"github.com/google/uuid"
 )
 
-// 生成认证的请求头, requestPath样例/server，不能包含协议和主机信息部分，且不应包含查询参数部分
-func generateAuthHeader(requestPath, requestMethod, apiToken, apiSecret string) (map[string]string, error) {
+// 生成认证的请求头, requestPath样例/server，不能包含协议和主机信息部分，且不应包含查询参数部分，
+// now为签名时间，authNonce为随机串，最长只能为32
+func generateAuthHeader(requestPath, requestMethod, apiToken, apiSecret string, now time.Time, authNonce string) (map[string]string, error) {
 	header := map[string]string{
 		"Content-Type": "application/json",
 	}
-	authTimestamp := strconv.Itoa(int(time.Now().Unix()))
-
-	// authNonce生成，最长只能为32
-	authNonce := strings.ReplaceAll(uuid.New().String(), "-", "")
+	authTimestamp := strconv.Itoa(int(now.Unix()))
 
 	// signature生成
-	authString := strings.Join([]string{apiToken, authTimestamp, authNonce, strings.ToUpper(requestMethod), requestPath}, "&")
+	authString := AuthString(apiToken, authTimestamp, authNonce, requestMethod, requestPath)
 	signature, err := generateHMACBase64(apiSecret, authString)
 	if err != nil {
 		return nil, err
@@ -36,6 +34,17 @@ func generateAuthHeader(requestPath, requestMethod, apiToken, apiSecret string)
 	return header, nil
 }
 
+// AuthString 生成pritunl签名使用的规范字符串，签名方和验签方共用，格式为token&timestamp&nonce&METHOD&path，
+// path不包含协议、主机和查询参数部分
+func AuthString(apiToken, authTimestamp, authNonce, requestMethod, requestPath string) string {
+	return strings.Join([]string{apiToken, authTimestamp, authNonce, strings.ToUpper(requestMethod), requestPath}, "&")
+}
+
+// generateNonce 生成authNonce，去掉uuid中的-后正好为32位
+func generateNonce() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
 // 生成signature
 func generateHMACBase64(secret, message string) (string, error) {
 	// 创建一个新的 HMAC-SHA256 哈希器
@@ -11,12 +11,16 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 // Config pritunl客户端配置
 type Config struct {
-	ApiToken     string // api token
-	ApiSecret    string // api secret
+	// Deprecated: 使用NewClientFromConfig的provider参数或者SetCredentialProvider管理认证信息，
+	// 未指定provider时ApiToken和ApiSecret作为固定的认证信息使用
+	ApiToken string
+	// Deprecated: 同ApiToken
+	ApiSecret    string
 	HttpProtocol string // http协议类型，默认是https
 	Host         string // pritunl主机地址
 	Context      *context.Context
@@ -28,15 +32,42 @@ type Client struct {
 	endpoint   string // http url base path
 	context    context.Context
 	httpClient *http.Client
+
+	credentials  *credentialHolder // 认证信息，请求签名时从这里获取，支持热更新
+	clock        *signClock        // 签名使用的时钟和随机串，包含与服务端的时间偏差
+	interceptors []Interceptor     // 请求拦截器链，按添加顺序执行BeforeSend，按相反顺序执行AfterReceive
+	tracer       Tracer            // 操作级别的追踪器，可为空
+	version      *versionHolder    // 服务端版本，首次需要时通过GetStatus探测
+}
+
+// Tracer 操作级别的追踪接口，用于记录InitVpnServer等由多个请求组成的高层操作，
+// Start返回携带该操作的context以及结束操作的函数，结束时传入操作的错误(可为nil)
+type Tracer interface {
+	Start(ctx context.Context, operation string) (context.Context, func(err error))
+}
+
+// Interceptor 请求拦截器，用于在请求发出前和收到响应后执行自定义逻辑，比如日志、监控、修改请求头等
+type Interceptor struct {
+	// BeforeSend 在请求发出前调用，可以修改请求或者返回一个新的请求，返回错误时请求不会发出，可为空
+	BeforeSend func(req *http.Request) (*http.Request, error)
+	// AfterReceive 在收到响应或者请求失败后调用，请求失败时resp为nil，err为请求错误，可为空。
+	// 此时还未校验响应状态码，也未读取响应体，如果读取了响应体，需要重新赋值一个可读的Body
+	AfterReceive func(req *http.Request, resp *http.Response, latency time.Duration, err error)
 }
 
 // NewClient 获取pritunl客户端
 func NewClient(apiToken, apiSecret, host string, context context.Context) (*Client, error) {
-	if len(apiToken) == 0 {
-		return nil, errors.New("api token不能为空")
+	creds := StaticCredentials{ApiToken: apiToken, ApiSecret: apiSecret}
+	if err := Credentials(creds).validate(); err != nil {
+		return nil, err
 	}
-	if len(apiSecret) == 0 {
-		return nil, errors.New("api secret不能为空")
+	return NewClientWithProvider(creds, host, context)
+}
+
+// NewClientWithProvider 获取使用指定认证信息提供者的pritunl客户端，每次请求签名时都会从provider获取认证信息
+func NewClientWithProvider(provider CredentialProvider, host string, context context.Context) (*Client, error) {
+	if provider == nil {
+		return nil, errors.New("credential provider不能为空")
 	}
 
 	httpClient := http.Client{Transport: &http.Transport{
@@ -45,12 +76,13 @@ func NewClient(apiToken, apiSecret, host string, context context.Context) (*Clie
 
 	client := Client{
 		config: Config{
-			ApiToken:  apiToken,
-			ApiSecret: apiSecret,
-			Host:      host,
+			Host: host,
 		},
-		endpoint:   fmt.Sprintf("https://%s", host),
-		httpClient: &httpClient,
+		endpoint:    fmt.Sprintf("https://%s", host),
+		httpClient:  &httpClient,
+		credentials: &credentialHolder{provider: provider},
+		clock:       newSignClock(),
+		version:     &versionHolder{},
 	}
 	if context != nil {
 		client.context = context
@@ -58,6 +90,90 @@ func NewClient(apiToken, apiSecret, host string, context context.Context) (*Clie
 	return &client, nil
 }
 
+// NewClientFromConfig 按配置获取pritunl客户端，provider为nil时使用配置中的ApiToken和ApiSecret作为固定的认证信息
+func NewClientFromConfig(config Config, provider CredentialProvider) (*Client, error) {
+	if provider == nil {
+		creds := StaticCredentials{ApiToken: config.ApiToken, ApiSecret: config.ApiSecret}
+		if err := Credentials(creds).validate(); err != nil {
+			return nil, err
+		}
+		provider = creds
+	}
+	var ctx context.Context
+	if config.Context != nil {
+		ctx = *config.Context
+	}
+	client, err := NewClientWithProvider(provider, config.Host, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.HttpProtocol) > 0 {
+		client.config.HttpProtocol = config.HttpProtocol
+		client.endpoint = fmt.Sprintf("%s://%s", config.HttpProtocol, config.Host)
+	}
+	return client, nil
+}
+
+// Use 为客户端添加拦截器，应在客户端开始使用之前调用
+func (c *Client) Use(interceptors ...Interceptor) {
+	c.interceptors = append(c.interceptors, interceptors...)
+}
+
+// SetCredentials 原子地替换客户端的认证信息，之后的请求(包括通过WithContext得到的副本)都会使用新的认证信息
+func (c *Client) SetCredentials(apiToken, apiSecret string) error {
+	creds := StaticCredentials{ApiToken: apiToken, ApiSecret: apiSecret}
+	if err := Credentials(creds).validate(); err != nil {
+		return err
+	}
+	c.credentials.set(creds)
+	return nil
+}
+
+// SetCredentialProvider 原子地替换客户端的认证信息提供者
+func (c *Client) SetCredentialProvider(provider CredentialProvider) error {
+	if provider == nil {
+		return errors.New("credential provider不能为空")
+	}
+	c.credentials.set(provider)
+	return nil
+}
+
+// SetClock 设置签名使用的时钟，默认为time.Now，主要用于单元测试中固定签名，应在客户端开始使用之前调用
+func (c *Client) SetClock(now func() time.Time) {
+	c.clock.clock = now
+}
+
+// SetNonceSource 设置签名使用的随机串生成函数，生成的随机串最长只能为32，应在客户端开始使用之前调用
+func (c *Client) SetNonceSource(nonce func() string) {
+	c.clock.nonceSource = nonce
+}
+
+// ClockSkew 返回当前检测到的服务端时间与本地时间的偏差，服务端时间较快时为正
+func (c *Client) ClockSkew() time.Duration {
+	return c.clock.skew()
+}
+
+// SetTracer 为客户端设置操作级别的追踪器，应在客户端开始使用之前调用
+func (c *Client) SetTracer(tracer Tracer) {
+	c.tracer = tracer
+}
+
+// WithContext 返回一个使用指定context的客户端副本，副本与原客户端共享http连接、拦截器等配置，
+// 用于为单次调用传递超时、取消以及链路追踪信息
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.context = ctx
+	return &clone
+}
+
+// startOperation 开始一个高层操作，未设置追踪器时不做任何事情
+func (c *Client) startOperation(ctx context.Context, operation string) (context.Context, func(err error)) {
+	if c.tracer == nil {
+		return ctx, func(error) {}
+	}
+	return c.tracer.Start(ctx, operation)
+}
+
 // serverUrl 返回完整的请求url
 func (c *Client) serverUrl(parts ...string) string {
 	return c.endpoint + strings.Join(parts, "/")
@@ -86,13 +202,50 @@ type RequestOpts struct {
 
 // Request 执行具体的请求
 func (c *Client) Request(method, path string, options *RequestOpts) (*http.Response, error) {
+	if options == nil {
+		options = &RequestOpts{}
+	}
+	resp, err := c.signedRequest(method, path, options)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// 会话认证模式下401说明会话已过期，重新登录后重试一次
+	if session := c.credentials.getSession(); session != nil {
+		session.invalidate()
+		if rewindBody(options) {
+			return c.signedRequest(method, path, options)
+		}
+		return resp, err
+	}
+
+	// 本地时间与服务端偏差过大时pritunl会返回401，根据响应的Date头校正时间偏差后重试一次
+	if c.clock.adjust(resp) && rewindBody(options) {
+		return c.signedRequest(method, path, options)
+	}
+	return resp, err
+}
+
+// signedRequest 签名并执行请求
+func (c *Client) signedRequest(method, path string, options *RequestOpts) (*http.Response, error) {
+	// 会话认证模式下添加Csrf-Token头，会话cookie由http客户端携带
+	if session := c.credentials.getSession(); session != nil {
+		header, err := session.header(c)
+		if err != nil {
+			return nil, err
+		}
+		options.MoreHeaders = header
+		return c.doRequest(strings.ToUpper(method), c.serverUrl(path), options)
+	}
+
 	// 添加认证头
-	authHeader, err := generateAuthHeader(path, method, c.config.ApiToken, c.config.ApiSecret)
+	creds, err := c.credentials.get()
 	if err != nil {
 		return nil, err
 	}
-	if options == nil {
-		options = &RequestOpts{}
+	authHeader, err := generateAuthHeader(path, method, creds.ApiToken, creds.ApiSecret, c.clock.now(), c.clock.nonce())
+	if err != nil {
+		return nil, err
 	}
 	options.MoreHeaders = authHeader
 	return c.doRequest(strings.ToUpper(method), c.serverUrl(path), options)
@@ -162,8 +315,27 @@ func (c *Client) doRequest(method, fullUrl string, options *RequestOpts) (*http.
 		req.Header.Del(v)
 	}
 
+	// 执行拦截器的BeforeSend
+	for _, interceptor := range c.interceptors {
+		if interceptor.BeforeSend == nil {
+			continue
+		}
+		if req, err = interceptor.BeforeSend(req); err != nil {
+			return nil, err
+		}
+	}
+
 	// 发出请求
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
+	latency := time.Since(start)
+
+	// 逆序执行拦截器的AfterReceive
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		if c.interceptors[i].AfterReceive != nil {
+			c.interceptors[i].AfterReceive(req, resp, latency, err)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,69 @@
+package pritunl
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// clockSkewThreshold 时间偏差的校正阈值，Date头只精确到秒，偏差变化小于该值时不认为是时间偏差导致的认证失败
+const clockSkewThreshold = 5 * time.Second
+
+// signClock 请求签名使用的时钟和随机串
+type signClock struct {
+	clock       func() time.Time
+	nonceSource func() string
+	offset      atomic.Int64 // 服务端时间减去本地时间，单位纳秒
+}
+
+// newSignClock 创建默认的签名时钟
+func newSignClock() *signClock {
+	return &signClock{
+		clock:       time.Now,
+		nonceSource: generateNonce,
+	}
+}
+
+// now 返回按时间偏差校正后的签名时间
+func (s *signClock) now() time.Time {
+	return s.clock().Add(s.skew())
+}
+
+// nonce 返回签名使用的随机串
+func (s *signClock) nonce() string {
+	return s.nonceSource()
+}
+
+// skew 返回当前的时间偏差
+func (s *signClock) skew() time.Duration {
+	return time.Duration(s.offset.Load())
+}
+
+// adjust 根据响应的Date头计算时间偏差，偏差有明显变化时更新偏差并返回true
+func (s *signClock) adjust(resp *http.Response) bool {
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return false
+	}
+	offset := serverTime.Sub(s.clock())
+	diff := offset - s.skew()
+	if diff < clockSkewThreshold && diff > -clockSkewThreshold {
+		return false
+	}
+	s.offset.Store(int64(offset))
+	return true
+}
+
+// rewindBody 重试前将请求体恢复到开头，请求体无法重放时返回false
+func rewindBody(options *RequestOpts) bool {
+	if options.RawBody == nil {
+		return true
+	}
+	seeker, ok := options.RawBody.(io.Seeker)
+	if !ok {
+		return false
+	}
+	_, err := seeker.Seek(0, io.SeekStart)
+	return err == nil
+}
@@ -0,0 +1,135 @@
+package pritunl
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testNonce = "0123456789abcdef0123456789abcdef"
+
+// newFixedClient 创建时钟和随机串固定的客户端，签名结果是确定的
+func newFixedClient(t *testing.T, handler http.Handler, now time.Time) *Client {
+	t.Helper()
+	client := newTestClient(t, handler)
+	client.SetClock(func() time.Time { return now })
+	client.SetNonceSource(func() string { return testNonce })
+	return client
+}
+
+func TestRequestSignatureDeterministic(t *testing.T) {
+	tests := []struct {
+		method    string
+		path      string
+		signature string
+	}{
+		// 期望值由HMAC-SHA256(secret, token&1700000000&nonce&METHOD&path)独立计算得到
+		{"get", "/status", "pGLODpx6TPbGsWSdpkTczZ/KtUMDQIbhxV2nDk2CT2Q="},
+		{"put", "/settings", "WyGKes78qPEP8c3779L1Sd16h73CaS5Lx8hzSDLRTxM="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+tt.path, func(t *testing.T) {
+			var header http.Header
+			client := newFixedClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header.Clone()
+				writeJSON(w, map[string]interface{}{})
+			}), time.Unix(1700000000, 0))
+
+			if _, err := client.Request(tt.method, tt.path, nil); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{
+				"Auth-Token":     "token",
+				"Auth-Timestamp": "1700000000",
+				"Auth-Nonce":     testNonce,
+				"Auth-Signature": tt.signature,
+			}
+			for key, value := range want {
+				if got := header.Get(key); got != value {
+					t.Errorf("%s = %q, want %q", key, got, value)
+				}
+			}
+		})
+	}
+}
+
+// skewedServer 服务端时间比客户端快一小时，签名时间与服务端时间不一致时返回401，响应的Date头为服务端时间
+type skewedServer struct {
+	now time.Time
+
+	mu         sync.Mutex
+	timestamps []string
+	bodies     []string
+}
+
+func (s *skewedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timestamps = append(s.timestamps, r.Header.Get("Auth-Timestamp"))
+	s.bodies = append(s.bodies, string(body))
+	w.Header().Set("Date", s.now.UTC().Format(http.TimeFormat))
+	if r.Header.Get("Auth-Timestamp") != strconv.FormatInt(s.now.Unix(), 10) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, map[string]interface{}{})
+}
+
+func TestRequestRetriesOnClockSkew(t *testing.T) {
+	local := time.Unix(1700000000, 0)
+	server := &skewedServer{now: local.Add(time.Hour)}
+	client := newFixedClient(t, server, local)
+
+	opts := &RequestOpts{RawBody: bytes.NewReader([]byte(`{"theme":"dark"}`))}
+	if _, err := client.Request("put", "/settings", opts); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1700000000", "1700003600"}
+	if strings.Join(server.timestamps, ",") != strings.Join(want, ",") {
+		t.Errorf("timestamps = %v, want exactly one re-signed retry %v", server.timestamps, want)
+	}
+	for i, body := range server.bodies {
+		if body != `{"theme":"dark"}` {
+			t.Errorf("body of attempt %d = %q, want replayed body", i+1, body)
+		}
+	}
+
+	// 校正后的偏差对后续请求生效，不再需要重试
+	if _, err := client.Request("get", "/status", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.timestamps) != 3 || server.timestamps[2] != "1700003600" {
+		t.Errorf("timestamps = %v, want skew applied to the next request", server.timestamps)
+	}
+}
+
+func TestRequestSkewRetryLimits(t *testing.T) {
+	local := time.Unix(1700000000, 0)
+
+	// 请求体无法重放时不重试
+	server := &skewedServer{now: local.Add(time.Hour)}
+	client := newFixedClient(t, server, local)
+	opts := &RequestOpts{RawBody: io.NopCloser(strings.NewReader("payload"))}
+	if _, err := client.Request("put", "/settings", opts); err == nil {
+		t.Error("request should fail with 401")
+	}
+	if len(server.timestamps) != 1 {
+		t.Errorf("attempts = %d, want no retry for a non-replayable body", len(server.timestamps))
+	}
+
+	// Date与本地时间一致时401不是时间偏差导致的，不重试
+	server = &skewedServer{now: local.Add(time.Second)}
+	client = newFixedClient(t, server, local)
+	if _, err := client.Request("get", "/status", nil); err == nil {
+		t.Error("request should fail with 401")
+	}
+	if len(server.timestamps) != 1 {
+		t.Errorf("attempts = %d, want no retry without clock skew", len(server.timestamps))
+	}
+}
@@ -0,0 +1,45 @@
+// pritunl-exporter 以prometheus格式导出pritunl的运行指标
+//
+// 用法：
+//
+//	PRITUNL_API_TOKEN=xxx PRITUNL_API_SECRET=xxx pritunl-exporter -host 192.170.1.193 -listen :9718
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	pritunl "github.com/alexzanda/pritunl-client"
+	"github.com/alexzanda/pritunl-client/collector"
+)
+
+func main() {
+	host := flag.String("host", "", "pritunl主机地址")
+	listen := flag.String("listen", ":9718", "指标服务监听地址")
+	path := flag.String("path", "/metrics", "指标服务路径")
+	cacheTTL := flag.Duration("cache-ttl", collector.DefaultCacheTTL, "抓取结果缓存时间")
+	flag.Parse()
+
+	if len(*host) == 0 {
+		log.Fatal("host不能为空")
+	}
+
+	client, err := pritunl.NewClient(os.Getenv("PRITUNL_API_TOKEN"), os.Getenv("PRITUNL_API_SECRET"), *host, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(*path, collector.New(client, *cacheTTL))
+
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("pritunl exporter listening on %s%s", *listen, *path)
+	log.Fatal(server.ListenAndServe())
+}
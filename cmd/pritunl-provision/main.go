@@ -0,0 +1,80 @@
+// pritunl-provision 按名单批量开户，并将所有用户的连接配置打包成zip
+//
+// 名单支持csv和json两种格式，按文件后缀区分，csv的表头为name,email,organization,groups，groups以分号分隔。
+// 执行完成后清单中记录每一行的结果，有失败的行时以非零状态退出，修复问题后加上-resume重新执行即可只处理失败的行
+//
+// 用法：
+//
+//	PRITUNL_API_TOKEN=xxx PRITUNL_API_SECRET=xxx pritunl-provision -host 192.170.1.193 -roster users.csv -bundle profiles.zip
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	pritunl "github.com/alexzanda/pritunl-client"
+)
+
+func main() {
+	host := flag.String("host", "", "pritunl主机地址")
+	rosterPath := flag.String("roster", "", "名单文件路径，.csv或.json")
+	bundle := flag.String("bundle", "profiles.zip", "配置文件zip包路径")
+	manifest := flag.String("manifest", "manifest.json", "清单文件路径")
+	resume := flag.Bool("resume", false, "跳过上一次已经成功的行")
+	concurrency := flag.Int("concurrency", pritunl.DefaultFleetConcurrency, "同时处理的用户数")
+	flag.Parse()
+
+	if len(*host) == 0 || len(*rosterPath) == 0 {
+		log.Fatal("host和roster不能为空")
+	}
+
+	roster, err := readRoster(*rosterPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := pritunl.NewClientWithProvider(pritunl.EnvCredentials{}, *host, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := pritunl.ProvisionUsers(ctx, client, roster, pritunl.ProvisionOpts{
+		Concurrency:  *concurrency,
+		BundlePath:   *bundle,
+		ManifestPath: *manifest,
+		Resume:       *resume,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	failed := result.Failed()
+	for _, entry := range failed {
+		log.Printf("row %d (%s/%s) failed: %s", entry.Row, entry.Organization, entry.Name, entry.Error)
+	}
+	log.Printf("provisioned %d/%d users, bundle: %s, manifest: %s",
+		len(result.Entries)-len(failed), len(result.Entries), *bundle, *manifest)
+	if len(failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+// readRoster 按文件后缀读取名单
+func readRoster(path string) ([]pritunl.RosterEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return pritunl.ParseRosterJSON(f)
+	}
+	return pritunl.ParseRosterCSV(f)
+}
@@ -0,0 +1,204 @@
+// Package collector 将pritunl的运行状态以prometheus文本格式(兼容OpenMetrics)导出，
+// Collector实现了http.Handler，可以直接挂载到/metrics路径供prometheus抓取
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pritunl "github.com/alexzanda/pritunl-client"
+)
+
+// DefaultCacheTTL 默认的抓取缓存时间，在此时间内的多次抓取直接返回缓存的结果，避免频繁请求pritunl
+const DefaultCacheTTL = 15 * time.Second
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collector pritunl指标采集器
+type Collector struct {
+	client   *pritunl.Client
+	cacheTTL time.Duration
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	cached    []byte
+	fetchedAt time.Time
+	lastErr   error
+}
+
+// New 创建一个采集器，cacheTTL为0时使用DefaultCacheTTL
+func New(client *pritunl.Client, cacheTTL time.Duration) *Collector {
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultCacheTTL
+	}
+	return &Collector{
+		client:   client,
+		cacheTTL: cacheTTL,
+		logger:   slog.Default(),
+	}
+}
+
+// SetLogger 设置记录抓取错误的日志，默认为slog.Default()，应在采集器开始使用之前调用
+func (c *Collector) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+}
+
+// LastError 返回最近一次抓取的错误，部分接口失败时为这些错误的合并，全部成功时为nil
+func (c *Collector) LastError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastErr
+}
+
+// ServeHTTP 输出prometheus文本格式的指标
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(c.scrape())
+}
+
+// scrape 返回指标内容，缓存未过期时直接返回缓存
+func (c *Collector) scrape() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached != nil && time.Since(c.fetchedAt) < c.cacheTTL {
+		return c.cached
+	}
+
+	start := time.Now()
+	metrics, calls, errs := c.collect()
+	duration := time.Since(start)
+
+	// 部分接口失败时仍输出成功采集到的指标，pritunl_up表示是否有接口调用成功，失败的接口数记录在pritunl_scrape_errors中
+	up := 0.0
+	if len(errs) < calls {
+		up = 1
+	}
+	c.lastErr = errors.Join(errs...)
+	if c.lastErr != nil && c.logger != nil {
+		c.logger.Error("scrape pritunl failed", slog.Int("errors", len(errs)), slog.String("error", c.lastErr.Error()))
+	}
+
+	buf := &bytes.Buffer{}
+	writeMetrics(buf, append(metrics,
+		metric{name: "pritunl_up", help: "Whether pritunl answered any request of the last scrape.", value: up},
+		metric{name: "pritunl_scrape_errors", help: "Number of failed requests in the last scrape of pritunl.", value: float64(len(errs))},
+		metric{name: "pritunl_scrape_duration_seconds", help: "Duration of the last scrape of pritunl.", value: duration.Seconds()},
+	))
+
+	c.cached = buf.Bytes()
+	c.fetchedAt = time.Now()
+	return c.cached
+}
+
+// metric 单个指标样本
+type metric struct {
+	name   string
+	help   string
+	labels [][2]string
+	value  float64
+}
+
+// collect 通过pritunl接口采集所有指标，某个接口失败时跳过其指标继续采集，返回采集到的指标、接口调用次数和失败的错误
+func (c *Collector) collect() ([]metric, int, []error) {
+	var metrics []metric
+	var errs []error
+	calls := 0
+
+	calls++
+	servers, err := pritunl.GetServerList(c.client)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("get server list failed, err: %w", err))
+	}
+	for _, server := range servers {
+		labels := [][2]string{{"server_id", server.Id}, {"server_name", server.Name}}
+		online := 0.0
+		if server.Status == "online" {
+			online = 1
+		}
+		metrics = append(metrics,
+			metric{name: "pritunl_server_online", help: "Whether the vpn server is online.", labels: labels, value: online},
+			metric{name: "pritunl_server_uptime_seconds", help: "Uptime of the vpn server.", labels: labels, value: float64(server.Uptime)},
+			metric{name: "pritunl_server_users_online", help: "Number of users connected to the vpn server.", labels: labels, value: float64(server.UsersOnline)},
+			metric{name: "pritunl_server_devices_online", help: "Number of devices connected to the vpn server.", labels: labels, value: float64(server.DevicesOnline)},
+		)
+
+		calls++
+		bandwidth, err := pritunl.GetServerBandwidth(c.client, server.Id, pritunl.BandwidthPeriod1m)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("get server %s bandwidth failed, err: %w", server.Id, err))
+			continue
+		}
+		metrics = append(metrics,
+			metric{name: "pritunl_server_received_bytes", help: "Bytes received by the vpn server in the last bandwidth period.", labels: labels, value: bandwidth.ReceivedTotal},
+			metric{name: "pritunl_server_sent_bytes", help: "Bytes sent by the vpn server in the last bandwidth period.", labels: labels, value: bandwidth.SentTotal},
+		)
+	}
+
+	calls++
+	orgs, err := pritunl.GetOrganizationList(c.client)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("get organizations failed, err: %w", err))
+	}
+	for _, org := range orgs {
+		calls++
+		users, err := pritunl.GetUserList(c.client, org.Id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("get organization %s users failed, err: %w", org.Id, err))
+			continue
+		}
+		disabled := 0
+		for _, user := range users {
+			if user.Disabled {
+				disabled++
+			}
+		}
+		labels := [][2]string{{"organization_id", org.Id}, {"organization_name", org.Name}}
+		metrics = append(metrics,
+			metric{name: "pritunl_organization_users", help: "Number of users in the organization.", labels: labels, value: float64(len(users))},
+			metric{name: "pritunl_organization_users_disabled", help: "Number of disabled users in the organization.", labels: labels, value: float64(disabled)},
+		)
+	}
+
+	return metrics, calls, errs
+}
+
+// writeMetrics 按prometheus文本格式输出指标，同名指标归为一组，只输出一次HELP和TYPE
+func writeMetrics(buf *bytes.Buffer, metrics []metric) {
+	groups := map[string][]metric{}
+	var names []string
+	for _, m := range metrics {
+		if _, ok := groups[m.name]; !ok {
+			names = append(names, m.name)
+		}
+		groups[m.name] = append(groups[m.name], m)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		group := groups[name]
+		fmt.Fprintf(buf, "# HELP %s %s\n", name, group[0].help)
+		fmt.Fprintf(buf, "# TYPE %s gauge\n", name)
+		for _, m := range group {
+			buf.WriteString(name)
+			if len(m.labels) > 0 {
+				pairs := make([]string, 0, len(m.labels))
+				for _, l := range m.labels {
+					pairs = append(pairs, fmt.Sprintf(`%s="%s"`, l[0], labelEscaper.Replace(l[1])))
+				}
+				fmt.Fprintf(buf, "{%s}", strings.Join(pairs, ","))
+			}
+			fmt.Fprintf(buf, " %g\n", m.value)
+		}
+	}
+}
+
+// labelEscaper 按prometheus文本格式转义标签值中的反斜杠、双引号和换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
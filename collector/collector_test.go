@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pritunl "github.com/alexzanda/pritunl-client"
+)
+
+func TestScrapePartialFailure(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/server":
+			_ = json.NewEncoder(w).Encode([]pritunl.VpnServer{{Id: "s1", Name: "office", Status: "online", UsersOnline: 3}})
+		case "/server/s1/bandwidth/1m":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		case "/organization":
+			_ = json.NewEncoder(w).Encode([]map[string]string{{"id": "o1", "name": "dev"}})
+		case "/user/o1":
+			_ = json.NewEncoder(w).Encode([]pritunl.UserDetail{{Id: "u1"}, {Id: "u2", Disabled: true}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := pritunl.NewClient("token", "secret", strings.TrimPrefix(srv.URL, "https://"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(client, 0)
+	c.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	body := string(c.scrape())
+	for _, want := range []string{
+		`pritunl_server_users_online{server_id="s1",server_name="office"} 3`,
+		`pritunl_organization_users_disabled{organization_id="o1",organization_name="dev"} 1`,
+		"pritunl_up 1\n",
+		"pritunl_scrape_errors 1\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "pritunl_server_sent_bytes") {
+		t.Errorf("bandwidth metrics should be skipped when the request fails:\n%s", body)
+	}
+	if err := c.LastError(); err == nil || !strings.Contains(err.Error(), "bandwidth") {
+		t.Errorf("LastError = %v, want bandwidth error", err)
+	}
+}
+
+func TestScrapeUnreachable(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client, err := pritunl.NewClient("token", "secret", strings.TrimPrefix(srv.URL, "https://"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(client, 0)
+	c.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	body := string(c.scrape())
+	if !strings.Contains(body, "pritunl_up 0\n") || !strings.Contains(body, "pritunl_scrape_errors 2\n") {
+		t.Errorf("unexpected metrics:\n%s", body)
+	}
+}
@@ -0,0 +1,304 @@
+package pritunl
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PritunlTotalConfig的加密存储。存储格式为json，非敏感字段明文保存便于查看，api token和api secret使用AES-256-GCM加密，
+// 明文部分作为附加认证数据参与加密，防止被篡改。格式带有版本号，后续版本的库能够继续读取旧版本保存的配置
+
+const (
+	// totalConfigFormatVersion 当前的存储格式版本
+	totalConfigFormatVersion = 1
+
+	kdfScrypt  = "scrypt"
+	kdfKeyFile = "keyfile"
+
+	configKeyLength = 32
+)
+
+// ConfigKey 配置加密密钥来源，Passphrase和KeyFile二选一
+type ConfigKey struct {
+	Passphrase string // 口令，使用scrypt派生密钥
+	KeyFile    string // 密钥文件路径，文件内容为32字节的原始密钥，或者其base64、hex编码
+}
+
+// scryptParams scrypt参数，保存在文件中，便于以后调整参数后仍能读取旧文件
+type scryptParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+var defaultScryptParams = scryptParams{N: 1 << 15, R: 8, P: 1}
+
+const (
+	maxScryptN      = 1 << 20 // scrypt参数N的上限
+	maxScryptR      = 32      // scrypt参数r的上限
+	maxScryptP      = 16      // scrypt参数p的上限
+	maxScryptMemory = 1 << 30 // scrypt派生密钥允许使用的最大内存，约为128*N*r字节
+)
+
+// validate 校验从文件读取的scrypt参数，防止被篡改的文件通过超大参数耗尽内存和cpu
+func (p scryptParams) validate() error {
+	if p.N < 2 || p.N > maxScryptN || p.N&(p.N-1) != 0 {
+		return fmt.Errorf("invalid scrypt param n: %d, must be a power of 2 not greater than %d", p.N, maxScryptN)
+	}
+	if p.R < 1 || p.R > maxScryptR {
+		return fmt.Errorf("invalid scrypt param r: %d, must be between 1 and %d", p.R, maxScryptR)
+	}
+	if p.P < 1 || p.P > maxScryptP {
+		return fmt.Errorf("invalid scrypt param p: %d, must be between 1 and %d", p.P, maxScryptP)
+	}
+	if 128*int64(p.N)*int64(p.R) > maxScryptMemory {
+		return fmt.Errorf("scrypt params n: %d, r: %d need too much memory", p.N, p.R)
+	}
+	return nil
+}
+
+// storedTotalConfig 存储格式
+type storedTotalConfig struct {
+	Version   int             `json:"version"`
+	Kdf       string          `json:"kdf"`
+	KdfParams *scryptParams   `json:"kdfParams,omitempty"`
+	Salt      string          `json:"salt,omitempty"`
+	Nonce     string          `json:"nonce"`
+	Config    json.RawMessage `json:"config"`  // 去除了敏感字段的配置
+	Secrets   string          `json:"secrets"` // 加密后的Credentials
+}
+
+// Redacted 返回敏感字段被脱敏的副本，可用于日志输出或者json.Marshal(conf.Redacted())
+func (c PritunlTotalConfig) Redacted() PritunlTotalConfig {
+	if len(c.ApiToken) > 0 {
+		c.ApiToken = RedactedValue
+	}
+	if len(c.ApiSecret) > 0 {
+		c.ApiSecret = RedactedValue
+	}
+	return c
+}
+
+// String 返回脱敏后的配置内容，避免打印配置时泄露api token和api secret
+func (c PritunlTotalConfig) String() string {
+	type plain PritunlTotalConfig
+	return fmt.Sprintf("%+v", plain(c.Redacted()))
+}
+
+// GenerateConfigKeyFile 生成一个随机的配置加密密钥文件，内容为base64编码，文件权限为0600
+func GenerateConfigKeyFile(path string) error {
+	key := make([]byte, configKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600)
+}
+
+// SaveTotalConfig 将配置加密保存到文件，文件权限为0600，先写临时文件再重命名，保证写入过程中不会损坏原文件
+func SaveTotalConfig(path string, conf PritunlTotalConfig, key ConfigKey) error {
+	buf := &bytes.Buffer{}
+	if err := EncodeTotalConfig(buf, conf, key); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadTotalConfig 从文件读取并解密配置
+func LoadTotalConfig(path string, key ConfigKey) (*PritunlTotalConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DecodeTotalConfig(f, key)
+}
+
+// EncodeTotalConfig 将配置加密后写入w
+func EncodeTotalConfig(w io.Writer, conf PritunlTotalConfig, key ConfigKey) error {
+	stored := storedTotalConfig{
+		Version: totalConfigFormatVersion,
+	}
+
+	var salt []byte
+	if len(key.Passphrase) > 0 {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		stored.Kdf = kdfScrypt
+		stored.KdfParams = &defaultScryptParams
+		stored.Salt = base64.StdEncoding.EncodeToString(salt)
+	} else {
+		stored.Kdf = kdfKeyFile
+	}
+	aead, err := newConfigAEAD(key, stored.Kdf, stored.KdfParams, salt)
+	if err != nil {
+		return err
+	}
+
+	// 明文部分不包含敏感字段
+	plain := conf
+	plain.ApiToken = ""
+	plain.ApiSecret = ""
+	if stored.Config, err = json.Marshal(plain); err != nil {
+		return err
+	}
+
+	secrets, err := json.Marshal(Credentials{ApiToken: conf.ApiToken, ApiSecret: conf.ApiSecret})
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+	stored.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	stored.Secrets = base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, secrets, stored.additionalData()))
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stored)
+}
+
+// DecodeTotalConfig 从r读取并解密配置
+func DecodeTotalConfig(r io.Reader, key ConfigKey) (*PritunlTotalConfig, error) {
+	var stored storedTotalConfig
+	if err := json.NewDecoder(r).Decode(&stored); err != nil {
+		return nil, fmt.Errorf("parse stored config failed, err: %w", err)
+	}
+	if stored.Version < 1 || stored.Version > totalConfigFormatVersion {
+		return nil, fmt.Errorf("unsupported stored config version: %d", stored.Version)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(stored.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt, err: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(stored.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce, err: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(stored.Secrets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secrets, err: %w", err)
+	}
+
+	aead, err := newConfigAEAD(key, stored.Kdf, stored.KdfParams, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
+	secrets, err := aead.Open(nil, nonce, sealed, stored.additionalData())
+	if err != nil {
+		return nil, errors.New("decrypt stored config failed, wrong key or config is corrupted")
+	}
+
+	var conf PritunlTotalConfig
+	if err = json.Unmarshal(stored.Config, &conf); err != nil {
+		return nil, fmt.Errorf("parse config failed, err: %w", err)
+	}
+	var creds Credentials
+	if err = json.Unmarshal(secrets, &creds); err != nil {
+		return nil, fmt.Errorf("parse secrets failed, err: %w", err)
+	}
+	conf.ApiToken = creds.ApiToken
+	conf.ApiSecret = creds.ApiSecret
+	return &conf, nil
+}
+
+// additionalData 参与认证的附加数据，包括版本号、密钥派生方式和明文配置，明文配置先压缩，不受文件缩进格式影响
+func (s storedTotalConfig) additionalData() []byte {
+	config := &bytes.Buffer{}
+	if err := json.Compact(config, s.Config); err != nil {
+		config.Write(s.Config)
+	}
+	return []byte(fmt.Sprintf("pritunl-total-config:%d:%s:%s", s.Version, s.Kdf, config.Bytes()))
+}
+
+// newConfigAEAD 按密钥来源构造AES-256-GCM
+func newConfigAEAD(key ConfigKey, kdf string, params *scryptParams, salt []byte) (cipher.AEAD, error) {
+	var secretKey []byte
+	var err error
+	switch kdf {
+	case kdfScrypt:
+		if len(key.Passphrase) == 0 {
+			return nil, errors.New("stored config is protected by passphrase, but passphrase is empty")
+		}
+		if params == nil {
+			params = &defaultScryptParams
+		}
+		if err = params.validate(); err != nil {
+			return nil, err
+		}
+		secretKey, err = scrypt.Key([]byte(key.Passphrase), salt, params.N, params.R, params.P, configKeyLength)
+		if err != nil {
+			return nil, err
+		}
+	case kdfKeyFile:
+		if len(key.KeyFile) == 0 {
+			return nil, errors.New("stored config is protected by key file, but key file is empty")
+		}
+		if secretKey, err = readConfigKeyFile(key.KeyFile); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kdf: %s", kdf)
+	}
+
+	block, err := aes.NewCipher(secretKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readConfigKeyFile 读取密钥文件，支持原始字节、base64和hex编码
+func readConfigKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == configKeyLength {
+		return content, nil
+	}
+
+	trimmed := string(bytes.TrimSpace(content))
+	if key, err := base64.StdEncoding.DecodeString(trimmed); err == nil && len(key) == configKeyLength {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(trimmed); err == nil && len(key) == configKeyLength {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key file %s must contain %d bytes key", path, configKeyLength)
+}
@@ -0,0 +1,152 @@
+package pritunl
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testTotalConfig() PritunlTotalConfig {
+	return PritunlTotalConfig{
+		PublicAddress: "vpn.example.com",
+		ApiToken:      "token-1234",
+		ApiSecret:     "secret-5678",
+		VpnServerId:   "server-1",
+		VpnPort:       1194,
+	}
+}
+
+func TestTotalConfigRoundTrip(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "config.key")
+	if err := GenerateConfigKeyFile(keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, key := range map[string]ConfigKey{
+		"passphrase": {Passphrase: "correct horse"},
+		"keyfile":    {KeyFile: keyFile},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conf := testTotalConfig()
+			buf := &bytes.Buffer{}
+			if err := EncodeTotalConfig(buf, conf, key); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(buf.String(), conf.ApiToken) || strings.Contains(buf.String(), conf.ApiSecret) {
+				t.Fatalf("stored config contains plaintext secrets:\n%s", buf)
+			}
+
+			decoded, err := DecodeTotalConfig(buf, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *decoded != conf {
+				t.Errorf("decoded = %+v, want %+v", *decoded, conf)
+			}
+		})
+	}
+}
+
+func TestTotalConfigSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pritunl.json")
+	key := ConfigKey{Passphrase: "correct horse"}
+	conf := testTotalConfig()
+	if err := SaveTotalConfig(path, conf, key); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTotalConfig(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *loaded != conf {
+		t.Errorf("loaded = %+v, want %+v", *loaded, conf)
+	}
+}
+
+func TestTotalConfigWrongPassphrase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := EncodeTotalConfig(buf, testTotalConfig(), ConfigKey{Passphrase: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeTotalConfig(buf, ConfigKey{Passphrase: "battery staple"}); err == nil {
+		t.Fatal("decode with wrong passphrase should fail")
+	}
+}
+
+func TestTotalConfigTampered(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := EncodeTotalConfig(buf, testTotalConfig(), ConfigKey{Passphrase: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(buf.String(), "vpn.example.com", "evil.example.com", 1)
+	if _, err := DecodeTotalConfig(strings.NewReader(tampered), ConfigKey{Passphrase: "correct horse"}); err == nil {
+		t.Fatal("decode of tampered config should fail")
+	}
+}
+
+func TestTotalConfigRejectsScryptParams(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := EncodeTotalConfig(buf, testTotalConfig(), ConfigKey{Passphrase: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, params := range map[string]scryptParams{
+		"huge n":        {N: 1 << 30, R: 8, P: 1},
+		"n not power 2": {N: 1000, R: 8, P: 1},
+		"huge r":        {N: 1 << 15, R: 1 << 20, P: 1},
+		"huge p":        {N: 1 << 15, R: 8, P: 1 << 20},
+		"huge memory":   {N: 1 << 20, R: 32, P: 1},
+		"zero":          {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			stored["kdfParams"] = params
+			content, err := json.Marshal(stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = DecodeTotalConfig(bytes.NewReader(content), ConfigKey{Passphrase: "correct horse"})
+			if err == nil || !strings.Contains(err.Error(), "scrypt") {
+				t.Errorf("err = %v, want scrypt params error", err)
+			}
+		})
+	}
+}
+
+func TestTotalConfigRedacted(t *testing.T) {
+	conf := testTotalConfig()
+
+	// 脱敏是可选的，直接序列化保留完整的认证信息
+	content, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded PritunlTotalConfig
+	if err = json.Unmarshal(content, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ApiToken != conf.ApiToken || decoded.ApiSecret != conf.ApiSecret {
+		t.Errorf("json.Marshal should keep credentials: %s", content)
+	}
+
+	if content, err = json.Marshal(conf.Redacted()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), conf.ApiToken) || strings.Contains(string(content), conf.ApiSecret) {
+		t.Errorf("redacted json contains plaintext secrets: %s", content)
+	}
+	if !strings.Contains(string(content), `"apiSecret":"`+RedactedValue+`"`) {
+		t.Errorf("redacted json should contain redacted apiSecret: %s", content)
+	}
+	if conf.ApiSecret == RedactedValue {
+		t.Error("Redacted should not modify the original config")
+	}
+	if strings.Contains(conf.String(), conf.ApiSecret) {
+		t.Errorf("String() contains plaintext secret: %s", conf.String())
+	}
+}
@@ -0,0 +1,201 @@
+package pritunl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Credentials api认证信息，json格式与PritunlTotalConfig中的认证字段一致
+type Credentials struct {
+	ApiToken  string `json:"apiToken"`
+	ApiSecret string `json:"apiSecret"`
+}
+
+// validate 校验认证信息是否完整
+func (c Credentials) validate() error {
+	if len(c.ApiToken) == 0 {
+		return errors.New("api token不能为空")
+	}
+	if len(c.ApiSecret) == 0 {
+		return errors.New("api secret不能为空")
+	}
+	return nil
+}
+
+// CredentialProvider 认证信息提供者，客户端每次请求签名时都会调用，实现需要保证并发安全，
+// 如果获取认证信息的代价较高，应由实现自行缓存
+type CredentialProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// credentialHolder 客户端持有的认证信息提供者，客户端副本之间共享，保证热更新对所有副本生效。
+// session不为空时客户端处于会话认证模式，不使用provider签名
+type credentialHolder struct {
+	mu       sync.RWMutex
+	provider CredentialProvider
+	session  *sessionAuth
+}
+
+// get 获取当前的认证信息
+func (h *credentialHolder) get() (Credentials, error) {
+	h.mu.RLock()
+	provider := h.provider
+	h.mu.RUnlock()
+
+	creds, err := provider.Credentials()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("get credentials failed, err: %w", err)
+	}
+	if err = creds.validate(); err != nil {
+		return Credentials{}, err
+	}
+	return creds, nil
+}
+
+// set 替换认证信息提供者，同时退出会话认证模式
+func (h *credentialHolder) set(provider CredentialProvider) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.provider = provider
+	h.session = nil
+}
+
+// setSession 切换为会话认证模式
+func (h *credentialHolder) setSession(session *sessionAuth) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.session = session
+}
+
+// getSession 返回会话认证状态，非会话认证模式时返回nil
+func (h *credentialHolder) getSession() *sessionAuth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.session
+}
+
+// StaticCredentials 固定的认证信息
+type StaticCredentials Credentials
+
+// Credentials 返回固定的认证信息
+func (s StaticCredentials) Credentials() (Credentials, error) {
+	return Credentials(s), nil
+}
+
+// EnvCredentials 从环境变量读取认证信息，变量名为空时使用PRITUNL_API_TOKEN和PRITUNL_API_SECRET
+type EnvCredentials struct {
+	TokenVar  string
+	SecretVar string
+}
+
+// Credentials 读取环境变量中的认证信息
+func (e EnvCredentials) Credentials() (Credentials, error) {
+	tokenVar, secretVar := e.TokenVar, e.SecretVar
+	if len(tokenVar) == 0 {
+		tokenVar = "PRITUNL_API_TOKEN"
+	}
+	if len(secretVar) == 0 {
+		secretVar = "PRITUNL_API_SECRET"
+	}
+	return Credentials{
+		ApiToken:  os.Getenv(tokenVar),
+		ApiSecret: os.Getenv(secretVar),
+	}, nil
+}
+
+// FileCredentials 从json文件读取认证信息，文件格式同Credentials。
+// 文件的修改时间或大小变化后会重新读取，便于外部轮换密钥
+type FileCredentials struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	creds   Credentials
+}
+
+// NewFileCredentials 创建基于文件的认证信息提供者
+func NewFileCredentials(path string) *FileCredentials {
+	return &FileCredentials{path: path}
+}
+
+// Credentials 返回文件中的认证信息，文件未变化时使用缓存
+func (f *FileCredentials) Credentials() (Credentials, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.creds, nil
+	}
+
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	var creds Credentials
+	if err = json.Unmarshal(content, &creds); err != nil {
+		return Credentials{}, fmt.Errorf("parse credentials file %s failed, err: %w", f.path, err)
+	}
+	f.creds = creds
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	return creds, nil
+}
+
+// ExecCredentials 通过执行外部命令获取认证信息，命令需要在标准输出打印Credentials格式的json
+type ExecCredentials struct {
+	name string
+	args []string
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	creds     Credentials
+	fetchedAt time.Time
+}
+
+// NewExecCredentials 创建基于外部命令的认证信息提供者，命令的结果缓存ttl时间，ttl小于等于0时每次请求都会执行命令
+func NewExecCredentials(ttl time.Duration, name string, args ...string) *ExecCredentials {
+	return &ExecCredentials{
+		name: name,
+		args: args,
+		ttl:  ttl,
+	}
+}
+
+// Credentials 执行命令获取认证信息，缓存未过期时使用缓存
+func (e *ExecCredentials) Credentials() (Credentials, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ttl > 0 && !e.fetchedAt.IsZero() && time.Since(e.fetchedAt) < e.ttl {
+		return e.creds, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, e.name, e.args...)
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("exec credential helper %s failed, err: %w, stderr: %s", e.name, err, stderr.String())
+	}
+
+	var creds Credentials
+	if err = json.Unmarshal(output, &creds); err != nil {
+		return Credentials{}, fmt.Errorf("parse credential helper output failed, err: %w", err)
+	}
+	e.creds = creds
+	e.fetchedAt = time.Now()
+	return creds, nil
+}
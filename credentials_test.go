@@ -0,0 +1,205 @@
+package pritunl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeCredentialsFile 写入认证信息文件，并设置修改时间，保证内容变化能被检测到
+func writeCredentialsFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv("PRITUNL_API_TOKEN", "env-token")
+	t.Setenv("PRITUNL_API_SECRET", "env-secret")
+	t.Setenv("CUSTOM_TOKEN", "custom-token")
+	t.Setenv("CUSTOM_SECRET", "custom-secret")
+
+	creds, err := EnvCredentials{}.Credentials()
+	if err != nil || creds.ApiToken != "env-token" || creds.ApiSecret != "env-secret" {
+		t.Errorf("EnvCredentials{}.Credentials() = %+v, %v", creds, err)
+	}
+	creds, err = EnvCredentials{TokenVar: "CUSTOM_TOKEN", SecretVar: "CUSTOM_SECRET"}.Credentials()
+	if err != nil || creds.ApiToken != "custom-token" || creds.ApiSecret != "custom-secret" {
+		t.Errorf("custom EnvCredentials = %+v, %v", creds, err)
+	}
+
+	// 变量缺失时在签名前被拒绝
+	holder := &credentialHolder{provider: EnvCredentials{TokenVar: "MISSING_TOKEN", SecretVar: "MISSING_SECRET"}}
+	if _, err = holder.get(); err == nil {
+		t.Error("missing env credentials should fail")
+	}
+}
+
+func TestFileCredentialsRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	modTime := time.Now().Add(-time.Hour)
+	writeCredentialsFile(t, path, `{"apiToken":"token-1","apiSecret":"secret-1"}`, modTime)
+
+	provider := NewFileCredentials(path)
+	creds, err := provider.Credentials()
+	if err != nil || creds.ApiToken != "token-1" {
+		t.Fatalf("Credentials() = %+v, %v", creds, err)
+	}
+
+	writeCredentialsFile(t, path, `{"apiToken":"token-2","apiSecret":"secret-2"}`, modTime.Add(time.Minute))
+	if creds, err = provider.Credentials(); err != nil || creds.ApiToken != "token-2" || creds.ApiSecret != "secret-2" {
+		t.Errorf("rotated Credentials() = %+v, %v", creds, err)
+	}
+
+	writeCredentialsFile(t, path, `not json`, modTime.Add(2*time.Minute))
+	if _, err = provider.Credentials(); err == nil {
+		t.Error("invalid credentials file should fail")
+	}
+	if err = os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = provider.Credentials(); err == nil {
+		t.Error("missing credentials file should fail")
+	}
+}
+
+func TestExecCredentials(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "calls")
+	script := `echo x >> "$1"; echo '{"apiToken":"exec-token","apiSecret":"exec-secret"}'`
+
+	provider := NewExecCredentials(time.Hour, "sh", "-c", script, "sh", counter)
+	for i := 0; i < 3; i++ {
+		creds, err := provider.Credentials()
+		if err != nil || creds.ApiToken != "exec-token" || creds.ApiSecret != "exec-secret" {
+			t.Fatalf("Credentials() = %+v, %v", creds, err)
+		}
+	}
+	calls, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(calls), "x"); n != 1 {
+		t.Errorf("helper executed %d times, want cached after the first call", n)
+	}
+
+	uncached := NewExecCredentials(0, "sh", "-c", script, "sh", counter)
+	for i := 0; i < 2; i++ {
+		if _, err = uncached.Credentials(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls, _ = os.ReadFile(counter); strings.Count(string(calls), "x") != 3 {
+		t.Errorf("helper without ttl should run on every call, calls: %q", calls)
+	}
+
+	if _, err = NewExecCredentials(0, "sh", "-c", "echo oops >&2; exit 3").Credentials(); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("failing helper = %v, want error with stderr", err)
+	}
+}
+
+func TestSetCredentialsWhileRequestsInFlight(t *testing.T) {
+	secrets := map[string]string{"token-1": "secret-1", "token-2": "secret-2"}
+	verifier := NewVerifier(func(token string) (string, bool) {
+		secret, ok := secrets[token]
+		return secret, ok
+	}, 0)
+	var tokens sync.Map
+	srv := httptest.NewTLSServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens.Store(r.Header.Get("Auth-Token"), true)
+		writeJSON(w, Status{ServerVersion: "1.32.3805.95"})
+	})))
+	t.Cleanup(srv.Close)
+
+	client, err := NewClient("token-1", "secret-1", strings.TrimPrefix(srv.URL, "https://"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	writeCredentialsFile(t, path, `{"apiToken":"token-2","apiSecret":"secret-2"}`, time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var failures atomic.Int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 通过WithContext得到的副本与原客户端共享认证信息
+			worker := client.WithContext(ctx)
+			for j := 0; j < 25; j++ {
+				if _, err := GetStatus(worker); err != nil {
+					failures.Add(1)
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			err = client.SetCredentialProvider(NewFileCredentials(path))
+		} else {
+			err = client.SetCredentials("token-1", "secret-1")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+
+	if failures.Load() > 0 {
+		t.Fatalf("%d requests signed with mismatched credentials", failures.Load())
+	}
+	if _, ok := tokens.Load("token-2"); !ok {
+		t.Error("rotated credentials were never used")
+	}
+	if err = client.SetCredentials("", "secret"); err == nil {
+		t.Error("SetCredentials with empty token should fail")
+	}
+	if _, err = GetStatus(client); err != nil {
+		t.Errorf("failed swap should keep the previous credentials, err: %v", err)
+	}
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	var token string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Auth-Token")
+		writeJSON(w, Status{})
+	}))
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	client, err := NewClientFromConfig(Config{ApiToken: "config-token", ApiSecret: "config-secret", Host: host}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = GetStatus(client); err != nil || token != "config-token" {
+		t.Errorf("deprecated config credentials not used: token %q, err %v", token, err)
+	}
+
+	client, err = NewClientFromConfig(Config{ApiToken: "config-token", Host: host}, StaticCredentials{ApiToken: "provider-token", ApiSecret: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = GetStatus(client); err != nil || token != "provider-token" {
+		t.Errorf("provider should take precedence: token %q, err %v", token, err)
+	}
+
+	if _, err = NewClientFromConfig(Config{Host: host}, nil); err == nil {
+		t.Error("config without credentials and provider should fail")
+	}
+}
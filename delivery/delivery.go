@@ -0,0 +1,220 @@
+// Package delivery 通过邮件向用户投递连接配置。邮件正文由可配置的文本和html模板渲染，连接配置文件作为附件发送，
+// 支持单个用户投递和按组织批量投递
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+
+	pritunl "github.com/alexzanda/pritunl-client"
+	"github.com/alexzanda/pritunl-client/internal/mailer"
+)
+
+// SMTPConfig 投递使用的smtp服务配置
+type SMTPConfig = mailer.Config
+
+// Message 渲染后的邮件
+type Message = mailer.Message
+
+// ProfileContentType 连接配置附件的类型
+const ProfileContentType = "application/x-openvpn-profile"
+
+// Template 邮件模板，Subject和Text使用text/template语法，HTML使用html/template语法，Text和HTML至少提供一个。
+// 模板中可以引用TemplateData的字段
+type Template struct {
+	Subject string
+	Text    string
+	HTML    string
+	Extra   map[string]string // 附加变量，比如客户端下载地址、联系人，模板中通过.Extra引用
+}
+
+// DefaultTemplate 默认的邮件模板
+var DefaultTemplate = Template{
+	Subject: "Your VPN profile for {{.OrganizationName}}",
+	Text: `Hello {{.UserName}},
+
+Your VPN connection profile is attached to this email:
+{{range .Files}}  - {{.}}
+{{end}}
+1. Install the Pritunl client or any OpenVPN client.
+2. Import the attached .ovpn file.
+3. Connect.
+
+Keep this profile private, it grants access to the network.
+`,
+}
+
+// TemplateData 渲染模板使用的数据
+type TemplateData struct {
+	UserName         string
+	Email            string
+	OrganizationName string
+	Files            []string // 附件文件名
+	Extra            map[string]string
+}
+
+// Deliverer 连接配置投递器
+type Deliverer struct {
+	smtp    SMTPConfig
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+	extra   map[string]string
+	send    func(context.Context, Message) error
+}
+
+// New 创建投递器，模板解析失败时返回错误
+func New(cfg SMTPConfig, tmpl Template) (*Deliverer, error) {
+	if len(tmpl.Text) == 0 && len(tmpl.HTML) == 0 {
+		return nil, errors.New("邮件模板的Text和HTML不能都为空")
+	}
+
+	d := &Deliverer{smtp: cfg, extra: tmpl.Extra}
+	var err error
+	if d.subject, err = texttemplate.New("subject").Parse(tmpl.Subject); err != nil {
+		return nil, fmt.Errorf("parse subject template failed, err: %w", err)
+	}
+	if len(tmpl.Text) > 0 {
+		if d.text, err = texttemplate.New("text").Parse(tmpl.Text); err != nil {
+			return nil, fmt.Errorf("parse text template failed, err: %w", err)
+		}
+	}
+	if len(tmpl.HTML) > 0 {
+		if d.html, err = htmltemplate.New("html").Parse(tmpl.HTML); err != nil {
+			return nil, fmt.Errorf("parse html template failed, err: %w", err)
+		}
+	}
+	d.send = func(ctx context.Context, msg Message) error {
+		return mailer.SendContext(ctx, d.smtp, msg)
+	}
+	return d, nil
+}
+
+// SetSender 替换邮件的发送方式，主要用于测试时不经过smtp服务
+func (d *Deliverer) SetSender(send func(Message) error) {
+	d.send = func(_ context.Context, msg Message) error {
+		return send(msg)
+	}
+}
+
+// Render 渲染发送给to的邮件，files作为附件
+func (d *Deliverer) Render(to string, data TemplateData, files ...pritunl.ConnectionFile) (Message, error) {
+	if len(files) == 0 {
+		return Message{}, errors.New("连接配置不能为空")
+	}
+	data.Email = to
+	data.Extra = d.extra
+	data.Files = nil
+	msg := Message{To: []string{to}}
+	for _, file := range files {
+		data.Files = append(data.Files, file.Name)
+		msg.Attachments = append(msg.Attachments, mailer.Attachment{
+			Name:        file.Name,
+			ContentType: ProfileContentType,
+			Content:     file.Content,
+		})
+	}
+
+	buf := bytes.Buffer{}
+	if err := d.subject.Execute(&buf, data); err != nil {
+		return Message{}, fmt.Errorf("render subject failed, err: %w", err)
+	}
+	msg.Subject = buf.String()
+	if d.text != nil {
+		buf.Reset()
+		if err := d.text.Execute(&buf, data); err != nil {
+			return Message{}, fmt.Errorf("render text failed, err: %w", err)
+		}
+		msg.Text = buf.String()
+	}
+	if d.html != nil {
+		buf.Reset()
+		if err := d.html.Execute(&buf, data); err != nil {
+			return Message{}, fmt.Errorf("render html failed, err: %w", err)
+		}
+		msg.HTML = buf.String()
+	}
+	return msg, nil
+}
+
+// DeliverFiles 将连接配置发送给to
+func (d *Deliverer) DeliverFiles(to string, data TemplateData, files ...pritunl.ConnectionFile) error {
+	return d.DeliverFilesContext(context.Background(), to, data, files...)
+}
+
+// DeliverFilesContext 将连接配置发送给to，ctx取消或到期时中断smtp连接
+func (d *Deliverer) DeliverFilesContext(ctx context.Context, to string, data TemplateData, files ...pritunl.ConnectionFile) error {
+	msg, err := d.Render(to, data, files...)
+	if err != nil {
+		return err
+	}
+	if err = d.send(ctx, msg); err != nil {
+		return fmt.Errorf("send profile to %s failed, err: %w", to, err)
+	}
+	return nil
+}
+
+// DeliverUser 导出用户的连接配置并发送到用户的邮箱
+func (d *Deliverer) DeliverUser(c *pritunl.Client, organizationId, userId string) error {
+	user, err := pritunl.GetUser(c, organizationId, userId)
+	if err != nil {
+		return fmt.Errorf("get user failed, err: %w", err)
+	}
+	return d.deliver(context.Background(), c, *user)
+}
+
+// deliver 导出并投递一个用户的连接配置
+func (d *Deliverer) deliver(ctx context.Context, c *pritunl.Client, user pritunl.UserDetail) error {
+	if len(user.Email) == 0 {
+		return fmt.Errorf("用户%s没有设置邮箱", user.Name)
+	}
+	files, err := pritunl.ExportUserConnectFiles(c, user.Organization, user.Id)
+	if err != nil {
+		return fmt.Errorf("export connection files failed, err: %w", err)
+	}
+	return d.DeliverFilesContext(ctx, user.Email, TemplateData{
+		UserName:         user.Name,
+		OrganizationName: user.OrganizationName,
+	}, files...)
+}
+
+// Result 批量投递中单个用户的结果
+type Result struct {
+	UserId   string
+	UserName string
+	Email    string
+	Skipped  bool // 用户被禁用或没有设置邮箱时跳过
+	Err      error
+}
+
+// DeliverOrganization 向组织下所有启用且设置了邮箱的用户投递连接配置。单个用户失败不影响其他用户，
+// 只有获取用户列表失败或ctx被取消时返回错误
+func (d *Deliverer) DeliverOrganization(ctx context.Context, c *pritunl.Client, organizationId string) ([]Result, error) {
+	client := c.WithContext(ctx)
+	users, err := pritunl.GetUserList(client, organizationId)
+	if err != nil {
+		return nil, fmt.Errorf("get organization users failed, err: %w", err)
+	}
+
+	results := make([]Result, 0, len(users))
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		result := Result{UserId: user.Id, UserName: user.Name, Email: user.Email}
+		if user.Disabled || len(user.Email) == 0 {
+			result.Skipped = true
+		} else {
+			if len(user.Organization) == 0 {
+				user.Organization = organizationId
+			}
+			result.Err = d.deliver(ctx, client, user)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,311 @@
+package delivery
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pritunl "github.com/alexzanda/pritunl-client"
+)
+
+// smtpMail smtp替身收到的一封邮件
+type smtpMail struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// smtpServer 最小的进程内smtp服务，不支持STARTTLS和认证，只记录收到的邮件
+type smtpServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	mails    []smtpMail
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) config() SMTPConfig {
+	return SMTPConfig{Host: "127.0.0.1", Port: s.listener.Addr().(*net.TCPAddr).Port, From: "vpn@example.com"}
+}
+
+func (s *smtpServer) received() []smtpMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMail(nil), s.mails...)
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+	reply("220 localhost ESMTP test")
+
+	var current smtpMail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			current = smtpMail{From: strings.Trim(line[len("MAIL FROM:"):], "<> ")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			current.To = append(current.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data := &bytes.Buffer{}
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			current.Data = data.Bytes()
+			s.mu.Lock()
+			s.mails = append(s.mails, current)
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// newPritunlServer pritunl用户详情和导出接口的替身
+func newPritunlServer(t *testing.T, users []pritunl.UserDetail) *pritunl.Client {
+	profiles := &bytes.Buffer{}
+	tw := tar.NewWriter(profiles)
+	content := "client\nremote vpn.example.com 1194 udp\n"
+	if err := tw.WriteHeader(&tar.Header{Name: "dev_alice_office.ovpn", Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = tw.Write([]byte(content))
+	_ = tw.Close()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/user/org1":
+			_ = json.NewEncoder(w).Encode(users)
+		case r.URL.Path == "/user/org1/u1":
+			_ = json.NewEncoder(w).Encode(users[0])
+		case strings.HasPrefix(r.URL.Path, "/key/org1/"):
+			_, _ = w.Write(profiles.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client, err := pritunl.NewClient("token", "secret", strings.TrimPrefix(srv.URL, "https://"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// parseMail 解析邮件，返回邮件头、正文和附件
+func parseMail(t *testing.T, data []byte) (mail.Header, string, map[string]string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := ""
+	attachments := map[string]string{}
+	var walk func(body io.Reader, contentType string)
+	walk = func(body io.Reader, contentType string) {
+		_, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			partType := part.Header.Get("Content-Type")
+			if strings.HasPrefix(partType, "multipart/") {
+				walk(part, partType)
+				continue
+			}
+			decoded, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, disposition, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); disposition["filename"] != "" {
+				attachments[disposition["filename"]] = string(decoded)
+			} else if strings.HasPrefix(partType, "text/plain") {
+				text = string(decoded)
+			}
+		}
+	}
+	walk(msg.Body, msg.Header.Get("Content-Type"))
+	return msg.Header, text, attachments
+}
+
+func TestDeliverUserOverSMTP(t *testing.T) {
+	smtpSrv := newSMTPServer(t)
+	client := newPritunlServer(t, []pritunl.UserDetail{{
+		Id: "u1", Name: "alice", Email: "alice@example.com", Organization: "org1", OrganizationName: "dev",
+	}})
+
+	d, err := New(smtpSrv.config(), DefaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = d.DeliverUser(client, "org1", "u1"); err != nil {
+		t.Fatal(err)
+	}
+
+	mails := smtpSrv.received()
+	if len(mails) != 1 {
+		t.Fatalf("received %d mails, want 1", len(mails))
+	}
+	if mails[0].From != "vpn@example.com" || len(mails[0].To) != 1 || mails[0].To[0] != "alice@example.com" {
+		t.Errorf("unexpected envelope: from %s, to %v", mails[0].From, mails[0].To)
+	}
+	header, text, attachments := parseMail(t, mails[0].Data)
+	if subject := header.Get("Subject"); !strings.Contains(subject, "dev") {
+		t.Errorf("subject = %q, want organization name", subject)
+	}
+	if !strings.Contains(text, "Hello alice") || !strings.Contains(text, "dev_alice_office.ovpn") {
+		t.Errorf("unexpected text body:\n%s", text)
+	}
+	if !strings.Contains(attachments["dev_alice_office.ovpn"], "remote vpn.example.com 1194 udp") {
+		t.Errorf("unexpected attachments: %v", attachments)
+	}
+}
+
+func TestDeliverOrganizationOverSMTP(t *testing.T) {
+	smtpSrv := newSMTPServer(t)
+	client := newPritunlServer(t, []pritunl.UserDetail{
+		{Id: "u1", Name: "alice", Email: "alice@example.com"},
+		{Id: "u2", Name: "bob"},
+		{Id: "u3", Name: "carol", Email: "carol@example.com", Disabled: true},
+	})
+
+	d, err := New(smtpSrv.config(), DefaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := d.DeliverOrganization(context.Background(), client, "org1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Err != nil || results[0].Skipped || !results[1].Skipped || !results[2].Skipped {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	if mails := smtpSrv.received(); len(mails) != 1 || mails[0].To[0] != "alice@example.com" {
+		t.Errorf("unexpected mails: %+v", mails)
+	}
+}
+
+func TestDeliverSMTPRejected(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.WriteString(conn, "554 no service\r\n")
+	}()
+
+	d, err := New(SMTPConfig{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port, From: "vpn@example.com"}, DefaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.DeliverFiles("alice@example.com", TemplateData{UserName: "alice"}, pritunl.ConnectionFile{Name: "a.ovpn", Content: []byte("client")})
+	if err == nil || !strings.Contains(err.Error(), "alice@example.com") {
+		t.Errorf("DeliverFiles() = %v, want smtp error", err)
+	}
+}
+
+func TestDeliverOrganizationCanceledDuringSMTP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// 接受连接后不发送问候，投递会一直阻塞到ctx到期
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	client := newPritunlServer(t, []pritunl.UserDetail{{Id: "u1", Name: "alice", Email: "alice@example.com"}})
+	d, err := New(SMTPConfig{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port, From: "vpn@example.com"}, DefaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	results, err := d.DeliverOrganization(ctx, client, "org1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("DeliverOrganization() took %v, want interrupted by ctx", elapsed)
+	}
+	if len(results) != 1 || !errors.Is(results[0].Err, context.DeadlineExceeded) {
+		t.Errorf("results = %+v, want deadline exceeded", results)
+	}
+}
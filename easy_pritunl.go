@@ -1,8 +1,8 @@
 package pritunl
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net"
 )
 
@@ -12,11 +12,12 @@ const (
 	DEFAULT_ADMIN_USER   = "pritunl"
 	DEFAULT_ORGANIZATION = "default"
 	DEFAULT_ROUTE        = "0.0.0.0/0"
+	DEFAULT_ROUTE6       = "::/0"
 )
 
 // PritunlTotalConfig 一个正常运行着的完整vpn服务所用到的配置
 type PritunlTotalConfig struct {
-	PublicAddress  string `json:"publicAddress"`  // 服务对外地址
+	PublicAddress  string `json:"publicAddress"`  // 服务对外的ipv4地址或域名，只配置了ipv6地址时为空
 	AdminUserId    string `json:"adminUserId"`    // 默认的管理员账号id
 	ApiToken       string `json:"apiToken"`       // api token
 	ApiSecret      string `json:"apiSecret"`      // api secret
@@ -30,137 +31,302 @@ type PritunlTotalConfig struct {
 	RouteId        string `json:"routeId"`        // vpn连接的内部网络的路由id
 	RouteUseNat    bool   `json:"routeUseNat"`    // vpn连接的内部网络是否启用nat模式
 
+	PublicAddress6 string `json:"publicAddress6,omitempty"` // 服务对外的ipv6地址
+	VpnNetwork6    string `json:"vpnNetwork6,omitempty"`    // vpn的ipv6网段，未启用ipv6时为空
+}
+
+// InitOpts 一键初始化vpn服务的配置
+type InitOpts struct {
+	AdminIp    string // pritunl管理地址
+	PublicAddr string // 服务对外地址，ip地址或者域名，为ipv6地址时作为PublicAddr6使用
+	Network    string // vpn连接的内部网络
+	UseNat     bool   // 内部网络是否启用nat模式
+	ApiToken   string // 默认的api token
+	ApiSecret  string // 默认的api secret
+	// AdminPassword 默认管理员的密码，镜像未开启api认证时，不传ApiToken和ApiSecret而传入密码，以会话认证方式完成初始化
+	AdminPassword string
+	// AdminOtp 获取默认管理员的一次性验证码，管理员开启了两步验证时需要提供，可为空
+	AdminOtp     func() (string, error)
+	Tracer       Tracer        // 操作级别的追踪器，可为空，整个初始化过程及每个步骤都会作为一个操作记录
+	Interceptors []Interceptor // 请求拦截器，可为空
+	PublicAddr6  string        // 服务对外的ipv6地址，可为空
+	Ipv6         bool          // 是否为vpn server启用ipv6
+	Network6     string        // vpn的ipv6网段，为空时由服务端自动生成
 }
 
 // InitVpnServer 一键初始化一个vpn服务。包括修改默认的认证key，创建vpn server、配置组织、路由、启动服务等
 func InitVpnServer(adminIp, publicAddr, network string, useNat bool, apiToken, apiSecret string) (*PritunlTotalConfig, error) {
-	// 校验外网地址
-	if net.ParseIP(publicAddr) == nil {
-		return nil, fmt.Errorf("public addr is invalid")
+	return InitVpnServerWithOpts(context.Background(), InitOpts{
+		AdminIp:    adminIp,
+		PublicAddr: publicAddr,
+		Network:    network,
+		UseNat:     useNat,
+		ApiToken:   apiToken,
+		ApiSecret:  apiSecret,
+	})
+}
+
+// newInitClient 按初始化配置创建客户端，没有api认证信息而提供了管理员密码时使用会话认证
+func newInitClient(ctx context.Context, initOpts InitOpts) (*Client, error) {
+	var client *Client
+	var err error
+	if len(initOpts.ApiToken) == 0 && len(initOpts.ApiSecret) == 0 && len(initOpts.AdminPassword) > 0 {
+		client, err = NewSessionClient(SessionCredentials{
+			Username: DEFAULT_ADMIN_USER,
+			Password: initOpts.AdminPassword,
+			Otp:      initOpts.AdminOtp,
+		}, initOpts.AdminIp, ctx)
+	} else {
+		client, err = NewClient(initOpts.ApiToken, initOpts.ApiSecret, initOpts.AdminIp, ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	client.Use(initOpts.Interceptors...)
+	client.SetTracer(initOpts.Tracer)
+	return client, nil
+}
+
+// InitVpnServerWithOpts 同InitVpnServer，ctx用于控制整个初始化过程的超时取消并传递链路追踪信息
+func InitVpnServerWithOpts(ctx context.Context, initOpts InitOpts) (totalConfig *PritunlTotalConfig, err error) {
+	// 校验外网地址，并按ipv4地址或域名、ipv6地址分开
+	publicAddr, publicAddr6, err := splitPublicAddress(initOpts.PublicAddr, initOpts.PublicAddr6)
+	if err != nil {
+		return nil, err
 	}
-	if _, _, err := net.ParseCIDR(network); err != nil {
+	if _, _, err := net.ParseCIDR(initOpts.Network); err != nil {
 		return nil, fmt.Errorf("network is invalid")
 	}
 
-	client, err := NewClient(apiToken, apiSecret, adminIp, nil)
+	client, err := newInitClient(ctx, initOpts)
 	if err != nil {
 		return nil, fmt.Errorf("create new client failed, err: %w", err)
 	}
 
+	ctx, end := client.startOperation(ctx, "pritunl.InitVpnServer")
+	defer func() { end(err) }()
+
+	// step 执行初始化的一个步骤，每个步骤作为一个子操作记录
+	step := func(name string, fn func(c *Client) error) error {
+		stepCtx, endStep := client.startOperation(ctx, "pritunl.InitVpnServer."+name)
+		stepErr := fn(client.WithContext(stepCtx))
+		endStep(stepErr)
+		return stepErr
+	}
+
 	totalConf := PritunlTotalConfig{}
 
 	// 获取管理账号列表
-	adminUsers, err := GetAdminUserList(client)
-	if err != nil {
-		return nil, fmt.Errorf("get admin user list failed, err: %w", err)
-	}
 	var defaultAdmin AdminUser
-	for _, adminUser := range adminUsers {
-		if adminUser.Username == DEFAULT_ADMIN_USER {
-			defaultAdmin = adminUser
-			break
+	err = step("GetAdminUserList", func(c *Client) error {
+		adminUsers, err := GetAdminUserList(c)
+		if err != nil {
+			return fmt.Errorf("get admin user list failed, err: %w", err)
+		}
+		for _, adminUser := range adminUsers {
+			if adminUser.Username == DEFAULT_ADMIN_USER {
+				defaultAdmin = adminUser
+				break
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	totalConf.AdminUserId = defaultAdmin.Id
 
 	// 更新admin用户的认证配置
-	updateAdminOpts := AdminUser{
-		Id:        defaultAdmin.Id,
-		Username:  defaultAdmin.Username,
-		AuthApi:   true,
-		SuperUser: true,
-	}
-	userConf, err := UpdateAdminUserAuthConfig(client, updateAdminOpts)
+	err = step("UpdateAdminUserAuthConfig", func(c *Client) error {
+		updateAdminOpts := AdminUser{
+			Id:        defaultAdmin.Id,
+			Username:  defaultAdmin.Username,
+			AuthApi:   true,
+			SuperUser: true,
+		}
+		userConf, err := UpdateAdminUserAuthConfig(c, updateAdminOpts)
+		if err != nil {
+			return fmt.Errorf("update admin user config failed, err: %w", err)
+		}
+		totalConf.ApiToken = userConf.Token
+		totalConf.ApiSecret = userConf.Secret
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("update admin user config failed, err: %w", err)
+		return nil, err
 	}
-	totalConf.ApiToken = userConf.Token
-	totalConf.ApiSecret = userConf.Secret
 
-	// 创建新的client
-	client, err = NewClient(totalConf.ApiToken, totalConf.ApiSecret, adminIp, nil)
-	if err != nil {
-		return nil, fmt.Errorf("create new client failed, err: %w", err)
+	// 切换为新的api认证信息，会话认证模式下同时切换为api认证
+	if err = client.SetCredentials(totalConf.ApiToken, totalConf.ApiSecret); err != nil {
+		return nil, fmt.Errorf("switch client credentials failed, err: %w", err)
 	}
 
 	// 创建一个新的server，如果vpn私有网段不指定，就自动生成
-	server := VpnServer{}
-	s, err := CreateVpnServer(client, server)
+	var s *VpnServer
+	err = step("CreateVpnServer", func(c *Client) error {
+		server, err := CreateVpnServer(c, VpnServer{Ipv6: initOpts.Ipv6, Network6: initOpts.Network6})
+		if err != nil {
+			return fmt.Errorf("create vpn server failed, err: %w", err)
+		}
+		s = server
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("create vpn server failed, err: %w", err)
+		return nil, err
 	}
 	totalConf.VpnServerName = s.Name
 	totalConf.VpnServerId = s.Id
 	totalConf.VpnNetwork = s.Network
+	totalConf.VpnNetwork6 = s.Network6
 	totalConf.VpnPort = s.Port
 
 	// 获取组织列表，内置的pritunl镜像，默认会内置一个组织，名为default
-	orgs, err := GetOrganizationList(client)
-	if err != nil {
-		return nil, fmt.Errorf("get organizations failed, err: %w", err)
-	}
 	var defaultOrg Organization
-	for _, org := range orgs {
-		if org.Name == DEFAULT_ORGANIZATION {
-			defaultOrg = org
-			break
+	err = step("GetOrganizationList", func(c *Client) error {
+		orgs, err := GetOrganizationList(c)
+		if err != nil {
+			return fmt.Errorf("get organizations failed, err: %w", err)
+		}
+		for _, org := range orgs {
+			if org.Name == DEFAULT_ORGANIZATION {
+				defaultOrg = org
+				break
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// 为server指定一个组织
-	attachConf := AttachConf{
-		Id:     defaultOrg.Id,
-		Server: s.Id,
-	}
-	if _, err = AttachOrganizationToServer(client, attachConf); err != nil {
-		return nil, fmt.Errorf("attach organization to server failed, err: %w", err)
+	err = step("AttachOrganizationToServer", func(c *Client) error {
+		attachConf := AttachConf{
+			Id:     defaultOrg.Id,
+			Server: s.Id,
+		}
+		if _, err := AttachOrganizationToServer(c, attachConf); err != nil {
+			return fmt.Errorf("attach organization to server failed, err: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	totalConf.OrganizationId = defaultOrg.Id
 
-	// 获取server的路由列表
-	rs, err := GetServerRouteList(client, s.Id)
-	if err != nil {
-		log.Fatal(err)
-	}
-	var defaultRoute RouteDetail
-	for _, route := range rs {
-		if route.Network == DEFAULT_ROUTE {
-			defaultRoute = route
-			break
+	// 获取server的路由列表，启用ipv6时还有一条ipv6的默认路由
+	var defaultRoutes []RouteDetail
+	err = step("GetServerRouteList", func(c *Client) error {
+		rs, err := GetServerRouteList(c, s.Id)
+		if err != nil {
+			return fmt.Errorf("get server routes failed, err: %w", err)
+		}
+		for _, route := range rs {
+			if route.Network == DEFAULT_ROUTE || route.Network == DEFAULT_ROUTE6 {
+				defaultRoutes = append(defaultRoutes, route)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// 删除默认的路由
-	if err = DeleteRoute(client, s.Id, defaultRoute.Id); err != nil {
-		return nil, fmt.Errorf("delete default route failed, err: %w", err)
+	err = step("DeleteRoute", func(c *Client) error {
+		for _, defaultRoute := range defaultRoutes {
+			if err := DeleteRoute(c, s.Id, defaultRoute.Id); err != nil {
+				return fmt.Errorf("delete default route %s failed, err: %w", defaultRoute.Network, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// 添加内网网段路由
-	route := RouteAddOpts{
-		Server:  s.Id,
-		Network: network,
-		Nat:     useNat,
-	}
-	r, err := AddRoute(client, route)
+	err = step("AddRoute", func(c *Client) error {
+		route := RouteAddOpts{
+			Server:  s.Id,
+			Network: initOpts.Network,
+			Nat:     initOpts.UseNat,
+		}
+		r, err := AddRoute(c, route)
+		if err != nil {
+			return fmt.Errorf("add internal route failed, err: %w", err)
+		}
+		totalConf.RouteId = r.Id
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("add internal route failed, err: %w", err)
+		return nil, err
 	}
-	totalConf.Route = network
-	totalConf.RouteId = r.Id
-	totalConf.RouteUseNat = useNat
+	totalConf.Route = initOpts.Network
+	totalConf.RouteUseNat = initOpts.UseNat
 
 	// 启动vpn server
-	s, err = StartStopServer(client, s.Id, true)
+	err = step("StartServer", func(c *Client) error {
+		if _, err := StartStopServer(c, s.Id, true); err != nil {
+			return fmt.Errorf("start vpn server failed, err: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("start vpn server failed, err: %w", err.Error())
+		return nil, err
+	}
+
+	// 等待vpn server真正运行起来
+	err = step("WaitForServerOnline", func(c *Client) error {
+		server, err := WaitForServerState(c.context, c, s.Id, ServerStatusOnline, &WaitOpts{
+			FailureStates: []string{ServerStatusOffline},
+		})
+		if err != nil {
+			return err
+		}
+		s = server
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	totalConf.VpnServerState = s.Status
 
 	// 更新服务端的public address, 这一步会导致pritunl服务端重启，需要放在最后一步
-	if _, err = UpdatePublicAccessAddress(client, publicAddr); err != nil {
-		return nil, fmt.Errorf("update public addr failed, err: %w", err)
+	restartWebServer := false
+	// ipv4和ipv6地址在同一个请求中提交，避免第二个请求撞上第一个请求触发的重启
+	err = step("UpdatePublicAccessAddress", func(c *Client) error {
+		settings := Settings{}
+		if len(publicAddr) > 0 {
+			settings.PublicAddress = String(publicAddr)
+		}
+		if len(publicAddr6) > 0 {
+			settings.PublicAddress6 = String(publicAddr6)
+		}
+		result, err := UpdateSettings(c, settings)
+		if err != nil {
+			return fmt.Errorf("update public addr failed, err: %w", err)
+		}
+		restartWebServer = result.RestartWebServer
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 等待pritunl web服务重启完成，服务端标记了重启时需要先等旧进程退出，否则旧进程仍会响应请求
+	err = step("WaitForAPIReady", func(c *Client) error {
+		if restartWebServer {
+			return WaitForAPIRestart(c.context, c, nil)
+		}
+		return WaitForAPIReady(c.context, c, nil)
+	})
+	if err != nil {
+		return nil, err
 	}
 	totalConf.PublicAddress = publicAddr
+	totalConf.PublicAddress6 = publicAddr6
 
 	return &totalConf, nil
 }
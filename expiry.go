@@ -0,0 +1,363 @@
+package pritunl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 用户限时访问。比赛等场景下为每个选手创建用户，并为其设置生效和过期时间，由ExpiryScheduler定时检查，
+// 到达生效时间时启用用户，到达过期时间时禁用或删除用户。计划保存在ExpiryStore中，进程重启后继续生效
+
+// ExpiryAction 用户过期后的动作
+type ExpiryAction string
+
+const (
+	ExpiryActionDisable ExpiryAction = "disable" // 过期后禁用用户
+	ExpiryActionDelete  ExpiryAction = "delete"  // 过期后删除用户
+)
+
+// ExpiryWindow 用户的访问时间窗口
+type ExpiryWindow struct {
+	ActivateAt time.Time    // 生效时间，为零值或者早于当前时间时立即生效，否则用户创建后先被禁用，到时间后启用
+	ExpireAt   time.Time    // 过期时间，不能为零值
+	Action     ExpiryAction // 过期后的动作，不给的话默认为disable
+}
+
+// ExpiryEntry 一个用户的限时访问计划
+type ExpiryEntry struct {
+	OrganizationId string       `json:"organizationId"`
+	UserId         string       `json:"userId"`
+	UserName       string       `json:"userName"`
+	ActivateAt     time.Time    `json:"activateAt"` // 为零值时创建后立即生效
+	ExpireAt       time.Time    `json:"expireAt"`
+	Action         ExpiryAction `json:"action"`
+	Activated      bool         `json:"activated"` // 是否已经启用
+}
+
+// key 计划的唯一标识
+func (e ExpiryEntry) key() string {
+	return e.OrganizationId + "/" + e.UserId
+}
+
+// ExpiryStore 限时访问计划的存储，实现需要保证并发安全
+type ExpiryStore interface {
+	// Save 保存计划，同一组织下同一用户的计划会被覆盖
+	Save(entry ExpiryEntry) error
+	// List 返回所有计划
+	List() ([]ExpiryEntry, error)
+	// Delete 删除计划，计划不存在时不报错
+	Delete(organizationId, userId string) error
+}
+
+// MemoryExpiryStore 内存中的计划存储，进程重启后计划会丢失，主要用于测试
+type MemoryExpiryStore struct {
+	mu      sync.Mutex
+	entries map[string]ExpiryEntry
+}
+
+// NewMemoryExpiryStore 创建内存计划存储
+func NewMemoryExpiryStore() *MemoryExpiryStore {
+	return &MemoryExpiryStore{entries: map[string]ExpiryEntry{}}
+}
+
+// Save 保存计划
+func (m *MemoryExpiryStore) Save(entry ExpiryEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[entry.key()] = entry
+	return nil
+}
+
+// List 返回所有计划，按过期时间排序
+func (m *MemoryExpiryStore) List() ([]ExpiryEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sortedEntries(m.entries), nil
+}
+
+// Delete 删除计划
+func (m *MemoryExpiryStore) Delete(organizationId, userId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, ExpiryEntry{OrganizationId: organizationId, UserId: userId}.key())
+	return nil
+}
+
+// FileExpiryStore 基于json文件的计划存储，每次修改都会完整重写文件
+type FileExpiryStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileExpiryStore 创建文件计划存储，文件不存在时会在第一次保存时创建
+func NewFileExpiryStore(path string) *FileExpiryStore {
+	return &FileExpiryStore{path: path}
+}
+
+// Save 保存计划
+func (f *FileExpiryStore) Save(entry ExpiryEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries, err := f.load()
+	if err != nil {
+		return err
+	}
+	entries[entry.key()] = entry
+	return f.write(entries)
+}
+
+// List 返回所有计划，按过期时间排序
+func (f *FileExpiryStore) List() ([]ExpiryEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return sortedEntries(entries), nil
+}
+
+// Delete 删除计划
+func (f *FileExpiryStore) Delete(organizationId, userId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries, err := f.load()
+	if err != nil {
+		return err
+	}
+	delete(entries, ExpiryEntry{OrganizationId: organizationId, UserId: userId}.key())
+	return f.write(entries)
+}
+
+// load 读取文件中的计划
+func (f *FileExpiryStore) load() (map[string]ExpiryEntry, error) {
+	entries := map[string]ExpiryEntry{}
+	content, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []ExpiryEntry
+	if err = json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("parse expiry store %s failed, err: %w", f.path, err)
+	}
+	for _, entry := range list {
+		entries[entry.key()] = entry
+	}
+	return entries, nil
+}
+
+// write 先写临时文件再重命名，避免写入过程中进程退出导致文件损坏
+func (f *FileExpiryStore) write(entries map[string]ExpiryEntry) error {
+	content, err := json.MarshalIndent(sortedEntries(entries), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err = os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// sortedEntries 按过期时间排序
+func sortedEntries(entries map[string]ExpiryEntry) []ExpiryEntry {
+	list := make([]ExpiryEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].ExpireAt.Equal(list[j].ExpireAt) {
+			return list[i].key() < list[j].key()
+		}
+		return list[i].ExpireAt.Before(list[j].ExpireAt)
+	})
+	return list
+}
+
+// AddUserWithExpiry 向组织添加用户，并按window记录限时访问计划。生效时间在未来时，用户创建时即为禁用状态。
+// 计划保存失败时会删除已创建的用户，避免留下没有过期计划的用户
+func AddUserWithExpiry(c *Client, store ExpiryStore, user UserAddOpts, window ExpiryWindow) ([]UserDetail, error) {
+	if window.ExpireAt.IsZero() {
+		return nil, errors.New("过期时间不能为空")
+	}
+	if !window.ActivateAt.IsZero() && !window.ActivateAt.Before(window.ExpireAt) {
+		return nil, errors.New("生效时间必须早于过期时间")
+	}
+	if len(window.Action) == 0 {
+		window.Action = ExpiryActionDisable
+	}
+
+	activated := window.ActivateAt.IsZero() || !window.ActivateAt.After(time.Now())
+	if !activated {
+		user.Disabled = true
+	}
+	users, err := AddUser(c, user)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, u := range users {
+		// 服务端没有按创建参数禁用用户时补充禁用，失败时回滚，避免用户提前获得访问权限
+		if !activated && !u.Disabled {
+			detail, err := EnableDisableUser(c, UserUpdateOpts{
+				UserId:         u.Id,
+				OrganizationId: user.OrganizationId,
+				Disabled:       true,
+			})
+			if err != nil {
+				err = fmt.Errorf("disable user %s before activation failed, err: %w", u.Id, err)
+				return nil, errors.Join(err, rollbackExpiryUsers(c, store, user.OrganizationId, users))
+			}
+			users[i] = *detail
+		}
+
+		entry := ExpiryEntry{
+			OrganizationId: user.OrganizationId,
+			UserId:         u.Id,
+			UserName:       u.Name,
+			ActivateAt:     window.ActivateAt,
+			ExpireAt:       window.ExpireAt,
+			Action:         window.Action,
+			Activated:      activated,
+		}
+		if err = store.Save(entry); err != nil {
+			err = fmt.Errorf("save expiry of user %s failed, err: %w", u.Id, err)
+			return nil, errors.Join(err, rollbackExpiryUsers(c, store, user.OrganizationId, users))
+		}
+	}
+	return users, nil
+}
+
+// rollbackExpiryUsers 删除已创建的用户及其计划，返回删除失败的错误
+func rollbackExpiryUsers(c *Client, store ExpiryStore, organizationId string, users []UserDetail) error {
+	var errs []error
+	for _, u := range users {
+		if err := DeleteUser(c, organizationId, u.Id); err != nil {
+			errs = append(errs, fmt.Errorf("rollback user %s failed, err: %w", u.Id, err))
+			continue
+		}
+		if err := store.Delete(organizationId, u.Id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ExpiryScheduler 限时访问计划的执行器
+type ExpiryScheduler struct {
+	client *Client
+	store  ExpiryStore
+	now    func() time.Time
+}
+
+// NewExpiryScheduler 创建计划执行器
+func NewExpiryScheduler(c *Client, store ExpiryStore) *ExpiryScheduler {
+	return &ExpiryScheduler{
+		client: c,
+		store:  store,
+		now:    time.Now,
+	}
+}
+
+// SetClock 设置判断生效和过期使用的时钟，默认为time.Now，主要用于单元测试，应在开始执行之前调用
+func (s *ExpiryScheduler) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// RunOnce 检查一次所有计划，启用到达生效时间的用户，禁用或删除到达过期时间的用户，返回本次处理过的计划。
+// 单个计划处理失败不影响其他计划，失败的计划保留在存储中下次重试，所有错误合并后返回
+func (s *ExpiryScheduler) RunOnce(ctx context.Context) ([]ExpiryEntry, error) {
+	entries, err := s.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	client := s.client.WithContext(ctx)
+	now := s.now()
+	var handled []ExpiryEntry
+	var errs []error
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		// 到达过期时间
+		if !now.Before(entry.ExpireAt) {
+			if err := s.expire(client, entry); err != nil {
+				errs = append(errs, fmt.Errorf("expire user %s failed, err: %w", entry.UserId, err))
+				continue
+			}
+			if err := s.store.Delete(entry.OrganizationId, entry.UserId); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			handled = append(handled, entry)
+			continue
+		}
+
+		// 到达生效时间
+		if !entry.Activated && !now.Before(entry.ActivateAt) {
+			_, err := EnableDisableUser(client, UserUpdateOpts{
+				UserId:         entry.UserId,
+				OrganizationId: entry.OrganizationId,
+				Disabled:       false,
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("activate user %s failed, err: %w", entry.UserId, err))
+				continue
+			}
+			entry.Activated = true
+			if err = s.store.Save(entry); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			handled = append(handled, entry)
+		}
+	}
+	return handled, errors.Join(errs...)
+}
+
+// expire 按计划的动作处理过期用户，用户已经不存在时视为处理完成
+func (s *ExpiryScheduler) expire(c *Client, entry ExpiryEntry) error {
+	var resp *http.Response
+	var err error
+	if entry.Action == ExpiryActionDelete {
+		resp, err = c.Request("delete", getDeleteUserUrl(entry.OrganizationId, entry.UserId), nil)
+	} else {
+		opts := RequestOpts{JSONBody: map[string]bool{"disabled": true}}
+		resp, err = c.resourceRequest("put", getUpdateUserUrl(entry.OrganizationId, entry.UserId), ResourceUser, &opts)
+	}
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// Run 按interval定时执行RunOnce，直到ctx取消。执行出错时调用onError(可为空)后继续
+func (s *ExpiryScheduler) Run(ctx context.Context, interval time.Duration, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunOnce(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package pritunl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeExpiryServer 用户创建、启用禁用和删除接口的替身，删除后的用户返回404
+type fakeExpiryServer struct {
+	ignoreDisabled bool // 为true时模拟创建用户时忽略disabled参数的服务端
+	failPut        bool // 为true时启用禁用用户失败
+
+	mu      sync.Mutex
+	users   map[string]UserDetail // 用户id -> 用户
+	created []UserAddOpts         // 创建用户的请求
+	deletes []string              // 删除用户的请求
+}
+
+func newFakeExpiryServer() *fakeExpiryServer {
+	return &fakeExpiryServer{users: map[string]UserDetail{}}
+}
+
+func (f *fakeExpiryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/status":
+		writeJSON(w, Status{ServerVersion: "1.32.3805.95"})
+	case len(parts) == 2 && parts[0] == "user" && r.Method == http.MethodPost:
+		var opts UserAddOpts
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.created = append(f.created, opts)
+		user := UserDetail{Id: "u-" + opts.Name, Name: opts.Name, Disabled: opts.Disabled && !f.ignoreDisabled}
+		f.users[user.Id] = user
+		writeJSON(w, []UserDetail{user})
+	case len(parts) == 3 && parts[0] == "user":
+		user, ok := f.users[parts[2]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			if f.failPut {
+				http.Error(w, "update failed", http.StatusInternalServerError)
+				return
+			}
+			var body map[string]bool
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			user.Disabled = body["disabled"]
+			f.users[user.Id] = user
+			writeJSON(w, user)
+		case http.MethodDelete:
+			f.deletes = append(f.deletes, user.Id)
+			delete(f.users, user.Id)
+			writeJSON(w, map[string]interface{}{})
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// failingExpiryStore 保存计划总是失败的存储
+type failingExpiryStore struct {
+	*MemoryExpiryStore
+}
+
+func (failingExpiryStore) Save(ExpiryEntry) error {
+	return errors.New("store unavailable")
+}
+
+func TestAddUserWithExpiryCreatesDisabledUser(t *testing.T) {
+	fake := newFakeExpiryServer()
+	client := newTestClient(t, fake)
+	store := NewMemoryExpiryStore()
+
+	window := ExpiryWindow{ActivateAt: time.Now().Add(time.Hour), ExpireAt: time.Now().Add(2 * time.Hour)}
+	users, err := AddUserWithExpiry(client, store, UserAddOpts{Name: "player1", OrganizationId: "org1"}, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fake.created[0].Disabled || !users[0].Disabled {
+		t.Errorf("user should be created disabled, request %+v, user %+v", fake.created[0], users[0])
+	}
+	entries, _ := store.List()
+	if len(entries) != 1 || entries[0].Activated {
+		t.Errorf("entries = %+v, want one pending entry", entries)
+	}
+
+	// 没有未来的生效时间时直接创建为启用状态
+	window.ActivateAt = time.Time{}
+	if users, err = AddUserWithExpiry(client, store, UserAddOpts{Name: "player2", OrganizationId: "org1"}, window); err != nil {
+		t.Fatal(err)
+	}
+	if fake.created[1].Disabled || users[0].Disabled {
+		t.Errorf("user without activation time should be enabled: %+v", users[0])
+	}
+}
+
+func TestAddUserWithExpiryRollback(t *testing.T) {
+	window := ExpiryWindow{ActivateAt: time.Now().Add(time.Hour), ExpireAt: time.Now().Add(2 * time.Hour)}
+	tests := []struct {
+		name    string
+		fake    *fakeExpiryServer
+		store   ExpiryStore
+		wantErr string
+	}{
+		{
+			name:    "failing store",
+			fake:    newFakeExpiryServer(),
+			store:   failingExpiryStore{NewMemoryExpiryStore()},
+			wantErr: "store unavailable",
+		},
+		{
+			name:    "failing disable",
+			fake:    &fakeExpiryServer{ignoreDisabled: true, failPut: true, users: map[string]UserDetail{}},
+			store:   NewMemoryExpiryStore(),
+			wantErr: "update failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, tt.fake)
+			users, err := AddUserWithExpiry(client, tt.store, UserAddOpts{Name: "player1", OrganizationId: "org1"}, window)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || users != nil {
+				t.Fatalf("AddUserWithExpiry() = %v, %v, want error %q", users, err, tt.wantErr)
+			}
+			if len(tt.fake.users) != 0 || len(tt.fake.deletes) != 1 {
+				t.Errorf("users = %v, deletes = %v, want the created user deleted", tt.fake.users, tt.fake.deletes)
+			}
+			if entries, _ := tt.store.List(); len(entries) != 0 {
+				t.Errorf("entries = %+v, want none left", entries)
+			}
+		})
+	}
+
+	// 服务端忽略disabled参数时补充禁用
+	fake := &fakeExpiryServer{ignoreDisabled: true, users: map[string]UserDetail{}}
+	users, err := AddUserWithExpiry(newTestClient(t, fake), NewMemoryExpiryStore(), UserAddOpts{Name: "player1", OrganizationId: "org1"}, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !users[0].Disabled || !fake.users["u-player1"].Disabled {
+		t.Errorf("user should be disabled before activation: %+v", users[0])
+	}
+}
+
+func TestExpirySchedulerRunOnce(t *testing.T) {
+	fake := newFakeExpiryServer()
+	client := newTestClient(t, fake)
+	store := NewMemoryExpiryStore()
+
+	// 生效时间需要晚于创建时的真实时间，用户才会被创建为禁用状态
+	start := time.Now().Add(24 * time.Hour)
+	add := func(name string, action ExpiryAction) {
+		window := ExpiryWindow{ActivateAt: start.Add(time.Hour), ExpireAt: start.Add(2 * time.Hour), Action: action}
+		if _, err := AddUserWithExpiry(client, store, UserAddOpts{Name: name, OrganizationId: "org1"}, window); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("disable-me", ExpiryActionDisable)
+	add("delete-me", ExpiryActionDelete)
+	add("gone", ExpiryActionDelete)
+
+	now := start
+	scheduler := NewExpiryScheduler(client, store)
+	scheduler.SetClock(func() time.Time { return now })
+
+	// 未到生效时间
+	if handled, err := scheduler.RunOnce(context.Background()); err != nil || len(handled) != 0 {
+		t.Fatalf("RunOnce() before activation = %v, %v", handled, err)
+	}
+
+	// 到达生效时间，用户被启用
+	now = start.Add(time.Hour)
+	handled, err := scheduler.RunOnce(context.Background())
+	if err != nil || len(handled) != 3 {
+		t.Fatalf("RunOnce() at activation = %v, %v", handled, err)
+	}
+	for _, id := range []string{"u-disable-me", "u-delete-me", "u-gone"} {
+		if user := fake.users[id]; user.Disabled {
+			t.Errorf("user %s should be activated", id)
+		}
+	}
+	if handled, _ = scheduler.RunOnce(context.Background()); len(handled) != 0 {
+		t.Errorf("activated entries should not be handled again: %+v", handled)
+	}
+
+	// 到达过期时间，按动作禁用或删除，已被删除的用户视为处理完成
+	fake.mu.Lock()
+	delete(fake.users, "u-gone")
+	fake.mu.Unlock()
+	now = start.Add(2 * time.Hour)
+	if handled, err = scheduler.RunOnce(context.Background()); err != nil || len(handled) != 3 {
+		t.Fatalf("RunOnce() at expiry = %v, %v", handled, err)
+	}
+	if user, ok := fake.users["u-disable-me"]; !ok || !user.Disabled {
+		t.Errorf("disable-me = %+v, want disabled", user)
+	}
+	if _, ok := fake.users["u-delete-me"]; ok {
+		t.Error("delete-me should be deleted")
+	}
+	if entries, _ := store.List(); len(entries) != 0 {
+		t.Errorf("entries = %+v, want all expired entries removed", entries)
+	}
+}
+
+func TestExpirySchedulerKeepsFailedEntries(t *testing.T) {
+	fake := newFakeExpiryServer()
+	client := newTestClient(t, fake)
+	store := NewMemoryExpiryStore()
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	window := ExpiryWindow{ExpireAt: start.Add(time.Hour)}
+	if _, err := AddUserWithExpiry(client, store, UserAddOpts{Name: "player1", OrganizationId: "org1"}, window); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduler := NewExpiryScheduler(client, store)
+	scheduler.SetClock(func() time.Time { return start.Add(time.Hour) })
+	fake.failPut = true
+	if _, err := scheduler.RunOnce(context.Background()); err == nil || !strings.Contains(err.Error(), "expire user u-player1 failed") {
+		t.Errorf("RunOnce() = %v, want expire error", err)
+	}
+	if entries, _ := store.List(); len(entries) != 1 {
+		t.Errorf("failed entry should be kept for retry: %+v", entries)
+	}
+
+	fake.failPut = false
+	if handled, err := scheduler.RunOnce(context.Background()); err != nil || len(handled) != 1 {
+		t.Errorf("retry RunOnce() = %v, %v", handled, err)
+	}
+}
+
+func TestFileExpiryStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expiry.json")
+	expireAt := time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)
+	pending := ExpiryEntry{
+		OrganizationId: "org1",
+		UserId:         "u1",
+		UserName:       "player1",
+		ActivateAt:     expireAt.Add(-time.Hour),
+		ExpireAt:       expireAt,
+		Action:         ExpiryActionDelete,
+	}
+	immediate := ExpiryEntry{OrganizationId: "org1", UserId: "u2", ExpireAt: expireAt.Add(time.Hour), Activated: true}
+
+	store := NewFileExpiryStore(path)
+	for _, entry := range []ExpiryEntry{pending, immediate, {OrganizationId: "org1", UserId: "u3", ExpireAt: expireAt}} {
+		if err := store.Save(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Delete("org1", "u3"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟进程重启，重新打开同一个文件
+	entries, err := NewFileExpiryStore(path).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v, want 2", entries)
+	}
+	if got := entries[0]; got.UserId != "u1" || !got.ActivateAt.Equal(pending.ActivateAt) || !got.ExpireAt.Equal(expireAt) ||
+		got.Action != ExpiryActionDelete || got.Activated {
+		t.Errorf("pending entry = %+v, want %+v", got, pending)
+	}
+	if got := entries[1]; got.UserId != "u2" || !got.ActivateAt.IsZero() || !got.Activated {
+		t.Errorf("immediate entry = %+v, want zero activation time", got)
+	}
+}
@@ -0,0 +1,181 @@
+package pritunl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// DefaultFleetConcurrency 默认的集群并发度
+const DefaultFleetConcurrency = 8
+
+// Fleet 多个pritunl实例组成的集群，按名称管理每个实例的客户端，并可在全部或部分实例上并发执行操作
+type Fleet struct {
+	mu          sync.RWMutex
+	clients     map[string]*Client
+	concurrency int
+}
+
+// FleetResult 单个实例上的执行结果
+type FleetResult[T any] struct {
+	Name  string // 实例名称
+	Value T      // 操作的返回值
+	Err   error  // 操作的错误
+}
+
+// NewFleet 创建一个集群，concurrency为同时执行操作的实例数上限，小于等于0时使用DefaultFleetConcurrency
+func NewFleet(concurrency int) *Fleet {
+	if concurrency <= 0 {
+		concurrency = DefaultFleetConcurrency
+	}
+	return &Fleet{
+		clients:     map[string]*Client{},
+		concurrency: concurrency,
+	}
+}
+
+// Register 按主机地址和认证信息注册一个实例
+func (f *Fleet) Register(name, host, apiToken, apiSecret string) error {
+	client, err := NewClient(apiToken, apiSecret, host, nil)
+	if err != nil {
+		return fmt.Errorf("create client for %s failed, err: %w", name, err)
+	}
+	return f.Add(name, client)
+}
+
+// Add 注册一个已创建好的客户端，名称不能重复
+func (f *Fleet) Add(name string, client *Client) error {
+	if len(name) == 0 {
+		return errors.New("实例名称不能为空")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.clients[name]; ok {
+		return fmt.Errorf("实例%s已存在", name)
+	}
+	f.clients[name] = client
+	return nil
+}
+
+// Remove 移除一个实例
+func (f *Fleet) Remove(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.clients, name)
+}
+
+// Client 获取指定实例的客户端
+func (f *Fleet) Client(name string) (*Client, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	client, ok := f.clients[name]
+	return client, ok
+}
+
+// Names 返回所有实例的名称，按名称排序
+func (f *Fleet) Names() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	names := make([]string, 0, len(f.clients))
+	for name := range f.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FleetRun 在集群的指定实例上并发执行操作，names为空时在所有实例上执行，重复的名称只执行一次，结果按名称排序返回。
+// op收到的客户端已绑定ctx，ctx为nil时使用context.Background()，ctx取消后尚未开始的实例不再执行，其结果的Err为ctx的错误
+func FleetRun[T any](ctx context.Context, f *Fleet, names []string, op func(ctx context.Context, c *Client) (T, error)) []FleetResult[T] {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(names) == 0 {
+		names = f.Names()
+	}
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	sorted = slices.Compact(sorted)
+
+	results := make([]FleetResult[T], len(sorted))
+	sem := make(chan struct{}, f.concurrency)
+	wg := sync.WaitGroup{}
+	for i, name := range sorted {
+		results[i].Name = name
+		client, ok := f.Client(name)
+		if !ok {
+			results[i].Err = fmt.Errorf("实例%s不存在", name)
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, client *Client) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i].Value, results[i].Err = op(ctx, client.WithContext(ctx))
+		}(i, client)
+	}
+	wg.Wait()
+	return results
+}
+
+// FleetErr 汇总集群执行结果中的错误，全部成功时返回nil
+func FleetErr[T any](results []FleetResult[T]) error {
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.Name, result.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListServersEverywhere 获取集群中各个实例的vpn server列表
+func ListServersEverywhere(ctx context.Context, f *Fleet, names []string) []FleetResult[[]VpnServer] {
+	return FleetRun(ctx, f, names, func(ctx context.Context, c *Client) ([]VpnServer, error) {
+		return GetServerList(c)
+	})
+}
+
+// DisableUserEverywhere 在集群各个实例的所有组织中查找指定名称的用户并禁用，返回每个实例上被禁用的用户
+func DisableUserEverywhere(ctx context.Context, f *Fleet, names []string, userName string) []FleetResult[[]UserDetail] {
+	return FleetRun(ctx, f, names, func(ctx context.Context, c *Client) ([]UserDetail, error) {
+		orgs, err := GetOrganizationList(c)
+		if err != nil {
+			return nil, fmt.Errorf("get organizations failed, err: %w", err)
+		}
+
+		disabled := []UserDetail{}
+		for _, org := range orgs {
+			users, err := GetUserList(c, org.Id)
+			if err != nil {
+				return disabled, fmt.Errorf("get organization %s users failed, err: %w", org.Id, err)
+			}
+			for _, user := range users {
+				if user.Name != userName {
+					continue
+				}
+				detail, err := EnableDisableUser(c, UserUpdateOpts{
+					UserId:         user.Id,
+					OrganizationId: org.Id,
+					Disabled:       true,
+				})
+				if err != nil {
+					return disabled, fmt.Errorf("disable user %s failed, err: %w", user.Id, err)
+				}
+				disabled = append(disabled, *detail)
+			}
+		}
+		return disabled, nil
+	})
+}
@@ -0,0 +1,106 @@
+package pritunl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestFleet 创建包含指定实例的集群，实例的客户端不会发出请求
+func newTestFleet(t *testing.T, concurrency int, names ...string) *Fleet {
+	t.Helper()
+	fleet := NewFleet(concurrency)
+	for _, name := range names {
+		if err := fleet.Register(name, name+".example.com", "token", "secret"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fleet
+}
+
+func TestFleetRunConcurrencyLimit(t *testing.T) {
+	fleet := newTestFleet(t, 2, "a", "b", "c", "d", "e", "f")
+
+	var running, maxRunning int32
+	results := FleetRun(context.Background(), fleet, nil, func(ctx context.Context, c *Client) (int, error) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return 0, nil
+	})
+	if len(results) != 6 {
+		t.Fatalf("results = %d, want 6", len(results))
+	}
+	if maxRunning != 2 {
+		t.Errorf("max concurrent operations = %d, want 2", maxRunning)
+	}
+}
+
+func TestFleetRunOrderingAndAggregation(t *testing.T) {
+	fleet := newTestFleet(t, 4, "c", "a", "b")
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	results := FleetRun(nil, fleet, []string{"c", "missing", "a", "c", "b", "a"}, func(ctx context.Context, c *Client) (string, error) {
+		if ctx == nil {
+			return "", errors.New("nil ctx")
+		}
+		name := strings.TrimPrefix(strings.TrimSuffix(c.endpoint, ".example.com"), "https://")
+		mu.Lock()
+		calls[name]++
+		mu.Unlock()
+		if name == "b" {
+			return "", errors.New("boom")
+		}
+		return "ok-" + name, nil
+	})
+
+	var got []string
+	for _, result := range results {
+		got = append(got, fmt.Sprintf("%s:%s:%v", result.Name, result.Value, result.Err))
+	}
+	want := []string{"a:ok-a:<nil>", "b::boom", "c:ok-c:<nil>", "missing::实例missing不存在"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("results = %v, want %v", got, want)
+	}
+	if calls["a"] != 1 || calls["b"] != 1 || calls["c"] != 1 {
+		t.Errorf("calls = %v, want every instance called once", calls)
+	}
+
+	err := FleetErr(results)
+	if err == nil || !strings.Contains(err.Error(), "b: boom") || !strings.Contains(err.Error(), "missing: ") {
+		t.Errorf("FleetErr() = %v", err)
+	}
+	if err = FleetErr(results[:1]); err != nil {
+		t.Errorf("FleetErr() of successful results = %v, want nil", err)
+	}
+}
+
+func TestFleetRunCanceled(t *testing.T) {
+	fleet := newTestFleet(t, 1, "a", "b")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	results := FleetRun(ctx, fleet, nil, func(ctx context.Context, c *Client) (int, error) {
+		cancel()
+		// 保持占用并发名额，确保后续实例等待名额时观察到取消
+		time.Sleep(20 * time.Millisecond)
+		return 1, nil
+	})
+	if results[0].Err != nil || results[0].Value != 1 {
+		t.Errorf("first result = %+v, want executed", results[0])
+	}
+	if !errors.Is(results[1].Err, context.Canceled) {
+		t.Errorf("second result = %+v, want context.Canceled", results[1])
+	}
+}
@@ -0,0 +1,72 @@
+package pritunl
+
+// 用户组。server设置了groups后，只有属于其中任一用户组的用户才能连接，未设置时组织下所有用户都可以连接
+
+// SetUserGroups 设置用户所属的用户组，groups为空时清空用户组
+func SetUserGroups(c *Client, organizationId, userId string, groups []string) (*UserDetail, error) {
+	if groups == nil {
+		groups = []string{}
+	}
+	return UpdateUser(c, organizationId, userId, UserModifyOpts{Groups: &groups})
+}
+
+// SetServerGroups 设置允许连接server的用户组，groups为空时取消限制，需要server处于停止状态
+func SetServerGroups(c *Client, serverId string, groups []string) (*VpnServer, error) {
+	if groups == nil {
+		groups = []string{}
+	}
+	return UpdateServer(c, serverId, ServerUpdateOpts{Groups: &groups})
+}
+
+// UserAllowedOnServer 判断用户是否满足server的用户组限制，不检查用户所在组织是否已添加到server
+func UserAllowedOnServer(user UserDetail, server VpnServer) bool {
+	if user.Disabled {
+		return false
+	}
+	if len(server.Groups) == 0 {
+		return true
+	}
+	for _, group := range user.Groups {
+		for _, allowed := range server.Groups {
+			if group == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AllowedUsers 计算组织下可以连接server的用户，组织未添加到server时返回空列表。
+// 用于在启动server前核对访问规则，禁用的用户不会出现在结果中
+func AllowedUsers(c *Client, serverId, organizationId string) ([]UserDetail, error) {
+	orgs, err := GetServerOrganizations(c, serverId)
+	if err != nil {
+		return nil, err
+	}
+	attached := false
+	for _, org := range orgs {
+		if org.Id == organizationId {
+			attached = true
+			break
+		}
+	}
+	if !attached {
+		return nil, nil
+	}
+
+	server, err := GetServer(c, serverId)
+	if err != nil {
+		return nil, err
+	}
+	users, err := GetUserList(c, organizationId)
+	if err != nil {
+		return nil, err
+	}
+	var allowed []UserDetail
+	for _, user := range users {
+		if UserAllowedOnServer(user, *server) {
+			allowed = append(allowed, user)
+		}
+	}
+	return allowed, nil
+}
@@ -0,0 +1,80 @@
+package pritunl
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestUserAllowedOnServer(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   UserDetail
+		groups []string
+		want   bool
+	}{
+		{"no server groups allows everyone", UserDetail{}, nil, true},
+		{"no server groups allows grouped user", UserDetail{Groups: []string{"dev"}}, nil, true},
+		{"shared group", UserDetail{Groups: []string{"ops", "dev"}}, []string{"dev", "qa"}, true},
+		{"no shared group", UserDetail{Groups: []string{"ops"}}, []string{"dev", "qa"}, false},
+		{"user without groups", UserDetail{}, []string{"dev"}, false},
+		{"group names are case sensitive", UserDetail{Groups: []string{"Dev"}}, []string{"dev"}, false},
+		{"disabled user", UserDetail{Disabled: true}, nil, false},
+		{"disabled user in shared group", UserDetail{Disabled: true, Groups: []string{"dev"}}, []string{"dev"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UserAllowedOnServer(tt.user, VpnServer{Groups: tt.groups}); got != tt.want {
+				t.Errorf("UserAllowedOnServer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowedUsers(t *testing.T) {
+	users := []UserDetail{
+		{Id: "u1", Name: "alice", Groups: []string{"dev"}},
+		{Id: "u2", Name: "bob", Groups: []string{"ops"}},
+		{Id: "u3", Name: "carol"},
+		{Id: "u4", Name: "dave", Groups: []string{"dev"}, Disabled: true},
+	}
+	tests := []struct {
+		name   string
+		orgId  string
+		groups []string
+		want   []string
+	}{
+		{"no groups allows every enabled user", "org1", nil, []string{"u1", "u2", "u3"}},
+		{"group intersection", "org1", []string{"dev", "qa"}, []string{"u1"}},
+		{"several groups", "org1", []string{"dev", "ops"}, []string{"u1", "u2"}},
+		{"organization not attached", "org2", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/server/s1/organization":
+					writeJSON(w, []AttachConf{{Id: "org1", Server: "s1"}})
+				case "/server/s1":
+					writeJSON(w, VpnServer{Id: "s1", Groups: tt.groups})
+				case "/user/org1":
+					writeJSON(w, users)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+
+			allowed, err := AllowedUsers(client, "s1", tt.orgId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, user := range allowed {
+				got = append(got, user.Id)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("AllowedUsers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
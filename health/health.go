@@ -0,0 +1,149 @@
+// Package health 为封装了pritunl客户端的服务提供kubernetes存活和就绪探针。
+// 存活探针只反映服务自身，不依赖pritunl，避免pritunl故障时服务被反复重启；
+// 就绪探针检查pritunl是否可访问、认证信息是否有效，pritunl不可用时服务暂时从负载均衡中摘除
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	pritunl "github.com/alexzanda/pritunl-client"
+)
+
+const (
+	DefaultCacheTTL = 10 * time.Second // 默认的检查结果缓存时间
+	DefaultTimeout  = 5 * time.Second  // 默认的单次检查超时时间
+)
+
+// Options 检查选项
+type Options struct {
+	CacheTTL   time.Duration // 检查结果缓存时间，不给的话默认为DefaultCacheTTL，避免探针频繁请求pritunl
+	Timeout    time.Duration // 单次检查超时时间，不给的话默认为DefaultTimeout
+	MinServers int           // 就绪时至少需要运行中的vpn server数，为0时不检查
+	MinVersion string        // 要求的最低pritunl版本，如1.30，为空时不检查
+}
+
+// Result 检查结果
+type Result struct {
+	Ready         bool      `json:"ready"`
+	Error         string    `json:"error,omitempty"`
+	ServerVersion string    `json:"serverVersion,omitempty"`
+	ServersOnline int       `json:"serversOnline"`
+	UsersOnline   int       `json:"usersOnline"`
+	CheckedAt     time.Time `json:"checkedAt"`
+}
+
+// Checker pritunl健康检查
+type Checker struct {
+	client *pritunl.Client
+	opts   Options
+	now    func() time.Time
+
+	mu     sync.Mutex
+	cached *Result
+}
+
+// New 创建健康检查
+func New(client *pritunl.Client, opts *Options) *Checker {
+	checker := &Checker{client: client, now: time.Now}
+	if opts != nil {
+		checker.opts = *opts
+	}
+	if checker.opts.CacheTTL <= 0 {
+		checker.opts.CacheTTL = DefaultCacheTTL
+	}
+	if checker.opts.Timeout <= 0 {
+		checker.opts.Timeout = DefaultTimeout
+	}
+	return checker
+}
+
+// Check 检查pritunl是否就绪，缓存未过期时直接返回缓存的结果。
+// 因ctx取消或到期(如探针请求断开)导致的失败不反映pritunl的状态，不会被缓存
+func (c *Checker) Check(ctx context.Context) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cached != nil && c.now().Sub(c.cached.CheckedAt) < c.opts.CacheTTL {
+		return *c.cached
+	}
+
+	result := c.check(ctx)
+	if ctx.Err() == nil {
+		c.cached = &result
+	}
+	return result
+}
+
+// check 执行一次检查
+func (c *Checker) check(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	client := c.client.WithContext(ctx)
+
+	result := Result{CheckedAt: c.now()}
+	if err := pritunl.Ping(client); err != nil {
+		result.Error = "ping failed: " + err.Error()
+		return result
+	}
+	status, err := pritunl.GetStatus(client)
+	if err != nil {
+		result.Error = "get status failed: " + err.Error()
+		return result
+	}
+	result.ServerVersion = status.ServerVersion
+	result.ServersOnline = status.ServersOnline
+	result.UsersOnline = status.UsersOnline
+
+	if status.ServersOnline < c.opts.MinServers {
+		result.Error = "not enough vpn servers online"
+		return result
+	}
+	if len(c.opts.MinVersion) > 0 {
+		if err := checkVersion(status.ServerVersion, c.opts.MinVersion); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+	result.Ready = true
+	return result
+}
+
+// checkVersion 检查服务端版本是否不低于minVersion
+func checkVersion(serverVersion, minVersion string) error {
+	required, err := pritunl.ParseVersion(minVersion)
+	if err != nil {
+		return err
+	}
+	version, err := pritunl.ParseVersion(serverVersion)
+	if err != nil {
+		return err
+	}
+	if !version.AtLeast(required) {
+		return fmt.Errorf("pritunl version %s is older than required %s", version, required)
+	}
+	return nil
+}
+
+// Liveness 存活探针，服务能响应即返回200
+func (c *Checker) Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
+
+// Readiness 就绪探针，pritunl就绪时返回200，否则返回503，响应体为Result的json
+func (c *Checker) Readiness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := c.Check(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if !result.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
@@ -0,0 +1,170 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pritunl "github.com/alexzanda/pritunl-client"
+)
+
+// fakePritunl pritunl替身，down为true时所有接口返回503
+type fakePritunl struct {
+	mu       sync.Mutex
+	down     bool
+	status   pritunl.Status
+	requests int
+}
+
+func (f *fakePritunl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	if f.down {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/ping":
+		_, _ = w.Write([]byte("{}"))
+	case "/status":
+		_ = json.NewEncoder(w).Encode(f.status)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakePritunl) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func newTestChecker(t *testing.T, fake *fakePritunl, opts *Options) *Checker {
+	t.Helper()
+	srv := httptest.NewTLSServer(fake)
+	t.Cleanup(srv.Close)
+	client, err := pritunl.NewClient("token", "secret", strings.TrimPrefix(srv.URL, "https://"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(client, opts)
+}
+
+func TestLiveness(t *testing.T) {
+	fake := &fakePritunl{down: true}
+	checker := newTestChecker(t, fake, nil)
+
+	rec := httptest.NewRecorder()
+	checker.Liveness().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok\n" {
+		t.Errorf("liveness = %d %q, want 200 ok", rec.Code, rec.Body.String())
+	}
+	if fake.requestCount() != 0 {
+		t.Errorf("liveness should not depend on pritunl, requests: %d", fake.requestCount())
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name      string
+		fake      *fakePritunl
+		opts      *Options
+		wantCode  int
+		wantError string
+	}{
+		{
+			name:     "ready",
+			fake:     &fakePritunl{status: pritunl.Status{ServerVersion: "1.32.3805.95", ServersOnline: 2, UsersOnline: 5}},
+			opts:     &Options{MinServers: 1, MinVersion: "1.30"},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "pritunl unavailable",
+			fake:      &fakePritunl{down: true},
+			wantCode:  http.StatusServiceUnavailable,
+			wantError: "ping failed",
+		},
+		{
+			name:      "not enough servers",
+			fake:      &fakePritunl{status: pritunl.Status{ServerVersion: "1.32.3805.95"}},
+			opts:      &Options{MinServers: 1},
+			wantCode:  http.StatusServiceUnavailable,
+			wantError: "not enough vpn servers online",
+		},
+		{
+			name:      "version too old",
+			fake:      &fakePritunl{status: pritunl.Status{ServerVersion: "1.29.2664.67"}},
+			opts:      &Options{MinVersion: "1.30"},
+			wantCode:  http.StatusServiceUnavailable,
+			wantError: "older than required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := newTestChecker(t, tt.fake, tt.opts)
+			rec := httptest.NewRecorder()
+			checker.Readiness().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			var result Result
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantCode || result.Ready != (tt.wantCode == http.StatusOK) {
+				t.Errorf("readiness = %d %+v, want %d", rec.Code, result, tt.wantCode)
+			}
+			if !strings.Contains(result.Error, tt.wantError) {
+				t.Errorf("error = %q, want %q", result.Error, tt.wantError)
+			}
+			if tt.wantCode == http.StatusOK && (result.ServersOnline != 2 || result.UsersOnline != 5 || result.ServerVersion != "1.32.3805.95") {
+				t.Errorf("unexpected result: %+v", result)
+			}
+		})
+	}
+}
+
+func TestCheckCacheExpiry(t *testing.T) {
+	fake := &fakePritunl{status: pritunl.Status{ServerVersion: "1.32.3805.95"}}
+	checker := newTestChecker(t, fake, &Options{CacheTTL: time.Minute})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	checker.now = func() time.Time { return now }
+
+	if result := checker.Check(context.Background()); !result.Ready {
+		t.Fatalf("Check() = %+v, want ready", result)
+	}
+	requests := fake.requestCount()
+
+	// 缓存未过期时不请求pritunl，即使pritunl已经不可用
+	fake.mu.Lock()
+	fake.down = true
+	fake.mu.Unlock()
+	now = now.Add(59 * time.Second)
+	if result := checker.Check(context.Background()); !result.Ready || fake.requestCount() != requests {
+		t.Errorf("Check() = %+v with %d requests, want the cached result", result, fake.requestCount()-requests)
+	}
+
+	now = now.Add(time.Second)
+	if result := checker.Check(context.Background()); result.Ready || fake.requestCount() == requests {
+		t.Errorf("Check() after ttl = %+v, want a fresh failed check", result)
+	}
+}
+
+func TestCheckCanceledNotCached(t *testing.T) {
+	fake := &fakePritunl{status: pritunl.Status{ServerVersion: "1.32.3805.95"}}
+	checker := newTestChecker(t, fake, &Options{CacheTTL: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result := checker.Check(ctx); result.Ready {
+		t.Fatalf("Check() with canceled ctx = %+v, want failed", result)
+	}
+	if result := checker.Check(context.Background()); !result.Ready {
+		t.Errorf("Check() = %+v, want the canceled result not cached", result)
+	}
+}
@@ -0,0 +1,242 @@
+// Package mailer 通过smtp发送邮件，支持STARTTLS、隐式TLS、认证以及附件，供通知和连接配置投递等功能共用
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config smtp服务配置
+type Config struct {
+	Host               string // smtp服务地址
+	Port               int    // smtp服务端口，不给的话默认为587，隐式TLS时默认为465
+	Username           string // 认证用户名，为空时不认证
+	Password           string // 认证密码
+	From               string // 发件人地址
+	ImplicitTLS        bool   // 是否使用隐式TLS(smtps)，否则在服务端支持时使用STARTTLS
+	RequireTLS         bool   // 服务端不支持STARTTLS时是否报错，否则以明文发送
+	InsecureSkipVerify bool   // 是否跳过服务端证书校验
+	Timeout            time.Duration
+}
+
+// Attachment 邮件附件
+type Attachment struct {
+	Name        string // 附件文件名
+	ContentType string // 附件类型，不给的话默认为application/octet-stream
+	Content     []byte
+}
+
+// Message 邮件内容，Text和HTML至少提供一个
+type Message struct {
+	To          []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Send 发送邮件
+func Send(cfg Config, msg Message) error {
+	return SendContext(context.Background(), cfg, msg)
+}
+
+// SendContext 发送邮件，ctx取消或到期时中断连接和发送，返回ctx的错误
+func SendContext(ctx context.Context, cfg Config, msg Message) error {
+	if err := send(ctx, cfg, msg); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("send mail canceled, err: %w", ctx.Err())
+		}
+		return err
+	}
+	return nil
+}
+
+// send 执行smtp交互
+func send(ctx context.Context, cfg Config, msg Message) error {
+	if len(cfg.Host) == 0 {
+		return errors.New("smtp host不能为空")
+	}
+	if len(cfg.From) == 0 {
+		return errors.New("发件人不能为空")
+	}
+	if len(msg.To) == 0 {
+		return errors.New("收件人不能为空")
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+		if cfg.ImplicitTLS {
+			port = 465
+		}
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: timeout}
+	if cfg.ImplicitTLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: tlsConfig}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("dial smtp server failed, err: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	// ctx取消或到期时关闭连接，中断阻塞中的smtp交互。不把ctx的截止时间设置为连接的超时时间，
+	// 否则连接可能先于ctx超时，返回的错误无法识别为ctx到期
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("create smtp client failed, err: %w", err)
+	}
+	defer client.Close()
+
+	if !cfg.ImplicitTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("smtp starttls failed, err: %w", err)
+			}
+		} else if cfg.RequireTLS {
+			return errors.New("smtp server does not support starttls")
+		}
+	}
+
+	if len(cfg.Username) > 0 {
+		if err = client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("smtp auth failed, err: %w", err)
+		}
+	}
+
+	if err = client.Mail(cfg.From); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err = client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp rcpt %s failed, err: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(Build(cfg.From, msg)); err != nil {
+		w.Close()
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Build 构造mime格式的邮件内容
+func Build(from string, msg Message) []byte {
+	buf := &bytes.Buffer{}
+	writeHeader(buf, "From", from)
+	writeHeader(buf, "To", strings.Join(msg.To, ", "))
+	writeHeader(buf, "Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader(buf, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(buf, "MIME-Version", "1.0")
+
+	body := &bytes.Buffer{}
+	contentType := writeAlternative(body, msg)
+	if len(msg.Attachments) > 0 {
+		boundary := newBoundary()
+		writeHeader(buf, "Content-Type", fmt.Sprintf(`multipart/mixed; boundary="%s"`, boundary))
+		buf.WriteString("\r\n")
+		fmt.Fprintf(buf, "--%s\r\n", boundary)
+		writeHeader(buf, "Content-Type", contentType)
+		buf.WriteString("\r\n")
+		buf.Write(body.Bytes())
+		for _, attachment := range msg.Attachments {
+			attachmentType := attachment.ContentType
+			if len(attachmentType) == 0 {
+				attachmentType = "application/octet-stream"
+			}
+			fmt.Fprintf(buf, "\r\n--%s\r\n", boundary)
+			writeHeader(buf, "Content-Type", mime.FormatMediaType(attachmentType, map[string]string{"name": attachment.Name}))
+			writeHeader(buf, "Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+			writeHeader(buf, "Content-Transfer-Encoding", "base64")
+			buf.WriteString("\r\n")
+			writeBase64(buf, attachment.Content)
+		}
+		fmt.Fprintf(buf, "\r\n--%s--\r\n", boundary)
+		return buf.Bytes()
+	}
+
+	writeHeader(buf, "Content-Type", contentType)
+	buf.WriteString("\r\n")
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+// writeAlternative 以multipart/alternative写入文本和html正文，返回正文的Content-Type
+func writeAlternative(buf *bytes.Buffer, msg Message) string {
+	boundary := newBoundary()
+	if len(msg.Text) > 0 {
+		fmt.Fprintf(buf, "--%s\r\n", boundary)
+		writePart(buf, "text/plain; charset=utf-8", msg.Text)
+		buf.WriteString("\r\n")
+	}
+	if len(msg.HTML) > 0 {
+		fmt.Fprintf(buf, "--%s\r\n", boundary)
+		writePart(buf, "text/html; charset=utf-8", msg.HTML)
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(buf, "--%s--\r\n", boundary)
+	return fmt.Sprintf(`multipart/alternative; boundary="%s"`, boundary)
+}
+
+// writePart 写入一个base64编码的正文part
+func writePart(buf *bytes.Buffer, contentType, content string) {
+	writeHeader(buf, "Content-Type", contentType)
+	writeHeader(buf, "Content-Transfer-Encoding", "base64")
+	buf.WriteString("\r\n")
+	writeBase64(buf, []byte(content))
+}
+
+// writeHeader 写入一个邮件头
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	fmt.Fprintf(buf, "%s: %s\r\n", key, value)
+}
+
+// writeBase64 按每行76个字符写入base64编码的内容
+func writeBase64(buf *bytes.Buffer, content []byte) {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+}
+
+// newBoundary 生成随机的mime分隔符
+func newBoundary() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "pritunl-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,72 @@
+package pritunl
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LogOptions 日志拦截器配置
+type LogOptions struct {
+	LogHeaders bool // 是否记录请求头和响应头，认证相关的头会被脱敏
+	LogBody    bool // 是否记录请求体和响应体，敏感字段和私钥会被脱敏，会将响应体完整读入内存，只建议调试时使用
+}
+
+// NewLogInterceptor 创建一个基于slog的日志拦截器，记录每个请求的method、path、状态码和耗时，
+// 通过Client.Use添加到客户端。opts为空时只记录基本信息
+func NewLogInterceptor(logger *slog.Logger, opts *LogOptions) Interceptor {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if opts == nil {
+		opts = &LogOptions{}
+	}
+
+	return Interceptor{
+		AfterReceive: func(req *http.Request, resp *http.Response, latency time.Duration, err error) {
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("path", req.URL.Path),
+				slog.Duration("latency", latency),
+			}
+			if opts.LogHeaders {
+				attrs = append(attrs, slog.Any("request_header", RedactHeader(req.Header)))
+			}
+			if opts.LogBody && req.GetBody != nil {
+				if body, err := req.GetBody(); err == nil {
+					if content, err := io.ReadAll(body); err == nil && len(content) > 0 {
+						attrs = append(attrs, slog.String("request_body", string(RedactBody(content))))
+					}
+				}
+			}
+
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+				logger.LogAttrs(req.Context(), slog.LevelError, "pritunl request failed", attrs...)
+				return
+			}
+
+			attrs = append(attrs, slog.Int("status", resp.StatusCode))
+			if opts.LogHeaders {
+				attrs = append(attrs, slog.Any("response_header", RedactHeader(resp.Header)))
+			}
+			if opts.LogBody {
+				content, err := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				// 读取后重新赋值响应体，保证后续流程可以正常解析
+				resp.Body = io.NopCloser(bytes.NewReader(content))
+				if err == nil && len(content) > 0 {
+					attrs = append(attrs, slog.String("response_body", string(RedactBody(content))))
+				}
+			}
+
+			level := slog.LevelInfo
+			if resp.StatusCode >= http.StatusBadRequest {
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(req.Context(), level, "pritunl request", attrs...)
+		},
+	}
+}
@@ -0,0 +1,62 @@
+// Package notify 监测pritunl的变化并通知到外部系统。Watcher定时轮询pritunl，将前后两次状态的差异转换为事件，
+// Notifier将事件投递到webhook、slack、邮件等目标，投递失败时按策略重试，最终失败的事件写入死信文件
+package notify
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType 事件类型
+type EventType string
+
+const (
+	EventServerOffline EventType = "server_offline" // vpn server离线
+	EventServerOnline  EventType = "server_online"  // vpn server上线
+	EventServerCreated EventType = "server_created" // 新建了vpn server
+	EventServerDeleted EventType = "server_deleted" // 删除了vpn server
+	EventUserCreated   EventType = "user_created"   // 新建了用户
+	EventUserDeleted   EventType = "user_deleted"   // 删除了用户
+	EventUserDisabled  EventType = "user_disabled"  // 用户被禁用
+	EventUserEnabled   EventType = "user_enabled"   // 用户被启用
+)
+
+// Event pritunl变化事件
+type Event struct {
+	Type             EventType `json:"type"`
+	Time             time.Time `json:"time"`
+	Source           string    `json:"source,omitempty"` // 事件来源，比如pritunl实例名称或者地址
+	ServerId         string    `json:"serverId,omitempty"`
+	ServerName       string    `json:"serverName,omitempty"`
+	OrganizationId   string    `json:"organizationId,omitempty"`
+	OrganizationName string    `json:"organizationName,omitempty"`
+	UserId           string    `json:"userId,omitempty"`
+	UserName         string    `json:"userName,omitempty"`
+}
+
+// Summary 返回事件的简短描述，用于聊天消息和邮件标题
+func (e Event) Summary() string {
+	prefix := ""
+	if len(e.Source) > 0 {
+		prefix = fmt.Sprintf("[%s] ", e.Source)
+	}
+	switch e.Type {
+	case EventServerOffline:
+		return fmt.Sprintf("%sserver %s is offline", prefix, e.ServerName)
+	case EventServerOnline:
+		return fmt.Sprintf("%sserver %s is online", prefix, e.ServerName)
+	case EventServerCreated:
+		return fmt.Sprintf("%sserver %s was created", prefix, e.ServerName)
+	case EventServerDeleted:
+		return fmt.Sprintf("%sserver %s was deleted", prefix, e.ServerName)
+	case EventUserCreated:
+		return fmt.Sprintf("%suser %s was created in organization %s", prefix, e.UserName, e.OrganizationName)
+	case EventUserDeleted:
+		return fmt.Sprintf("%suser %s was deleted from organization %s", prefix, e.UserName, e.OrganizationName)
+	case EventUserDisabled:
+		return fmt.Sprintf("%suser %s in organization %s was disabled", prefix, e.UserName, e.OrganizationName)
+	case EventUserEnabled:
+		return fmt.Sprintf("%suser %s in organization %s was enabled", prefix, e.UserName, e.OrganizationName)
+	}
+	return fmt.Sprintf("%s%s", prefix, e.Type)
+}
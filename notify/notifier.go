@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink 事件投递目标
+type Sink interface {
+	// Name 投递目标名称，用于死信记录
+	Name() string
+	// Send 投递一个事件
+	Send(ctx context.Context, event Event) error
+}
+
+// RetryPolicy 投递失败时的重试策略，第n次重试前等待Backoff*2^(n-1)，最长不超过MaxBackoff
+type RetryPolicy struct {
+	Attempts   int           // 最多尝试次数，包括第一次，小于等于0时为1
+	Backoff    time.Duration // 第一次重试前的等待时间
+	MaxBackoff time.Duration // 最长等待时间，为0时不限制
+}
+
+// DefaultRetryPolicy 默认的重试策略
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   5,
+	Backoff:    time.Second,
+	MaxBackoff: time.Minute,
+}
+
+// DeadLetter 死信记录，投递最终失败的事件会以json行的形式追加到死信文件中
+type DeadLetter struct {
+	Time  time.Time `json:"time"`
+	Sink  string    `json:"sink"`
+	Error string    `json:"error"`
+	Event Event     `json:"event"`
+}
+
+// Notifier 将事件投递到多个目标
+type Notifier struct {
+	sinks          []Sink
+	retry          RetryPolicy
+	deadLetterPath string
+
+	mu sync.Mutex // 保护死信文件的写入
+}
+
+// NewNotifier 创建通知器，deadLetterPath为死信文件路径，为空时丢弃投递失败的事件
+func NewNotifier(retry RetryPolicy, deadLetterPath string, sinks ...Sink) *Notifier {
+	if retry.Attempts <= 0 {
+		retry.Attempts = 1
+	}
+	return &Notifier{
+		sinks:          sinks,
+		retry:          retry,
+		deadLetterPath: deadLetterPath,
+	}
+}
+
+// Notify 将事件并发投递到所有目标，每个目标按顺序投递事件，返回时所有投递已经完成或者进入死信。
+// 投递失败的事件写入死信文件失败时返回这些错误，此时事件已经丢失
+func (n *Notifier) Notify(ctx context.Context, events []Event) error {
+	var errs []error
+	errMu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, sink := range n.sinks {
+		wg.Add(1)
+		go func(sink Sink) {
+			defer wg.Done()
+			for _, event := range events {
+				sendErr := n.send(ctx, sink, event)
+				if sendErr == nil {
+					continue
+				}
+				if err := n.deadLetter(sink, event, sendErr); err != nil {
+					errMu.Lock()
+					errs = append(errs, fmt.Errorf("write dead letter of sink %s failed, err: %w, send err: %v", sink.Name(), err, sendErr))
+					errMu.Unlock()
+				}
+			}
+		}(sink)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// send 按重试策略投递事件
+func (n *Notifier) send(ctx context.Context, sink Sink, event Event) error {
+	var err error
+	backoff := n.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		if err = sink.Send(ctx, event); err == nil {
+			return nil
+		}
+		if attempt >= n.retry.Attempts {
+			return fmt.Errorf("send event failed after %d attempts, err: %w", attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("send event canceled, last err: %w", err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if n.retry.MaxBackoff > 0 && backoff > n.retry.MaxBackoff {
+			backoff = n.retry.MaxBackoff
+		}
+	}
+}
+
+// deadLetter 将投递失败的事件写入死信文件，未配置死信文件时直接丢弃
+func (n *Notifier) deadLetter(sink Sink, event Event, sendErr error) error {
+	if len(n.deadLetterPath) == 0 {
+		return nil
+	}
+	line, err := json.Marshal(DeadLetter{
+		Time:  time.Now(),
+		Sink:  sink.Name(),
+		Error: sendErr.Error(),
+		Event: event,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, err := os.OpenFile(n.deadLetterPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingSink struct{}
+
+func (failingSink) Name() string { return "failing" }
+
+func (failingSink) Send(ctx context.Context, event Event) error {
+	return errors.New("boom")
+}
+
+func TestNotifierDeadLetter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	n := NewNotifier(RetryPolicy{Attempts: 1}, path, failingSink{})
+	if err := n.Notify(context.Background(), []Event{{Type: EventServerOffline, ServerName: "office"}}); err != nil {
+		t.Fatalf("Notify() = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var letter DeadLetter
+	if err = json.Unmarshal(content, &letter); err != nil {
+		t.Fatal(err)
+	}
+	if letter.Sink != "failing" || letter.Event.ServerName != "office" || !strings.Contains(letter.Error, "boom") {
+		t.Errorf("unexpected dead letter: %+v", letter)
+	}
+}
+
+func TestNotifierDeadLetterWriteError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "dead.jsonl")
+	n := NewNotifier(RetryPolicy{Attempts: 1}, path, failingSink{})
+	err := n.Notify(context.Background(), []Event{{Type: EventServerOffline}})
+	if err == nil || !strings.Contains(err.Error(), "dead letter") {
+		t.Errorf("Notify() = %v, want dead letter write error", err)
+	}
+}
+
+// smtp服务端接受连接后不返回欢迎信息，ctx取消后发送应立即返回
+func TestEmailSinkCanceled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	sink := &EmailSink{
+		SMTP: SMTPConfig{Host: "127.0.0.1", Port: addr.Port, From: "vpn@example.com", Timeout: time.Minute},
+		To:   []string{"ops@example.com"},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = sink.Send(ctx, Event{Type: EventServerOffline})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send() = %v, want context deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Send() took %s after ctx was done", elapsed)
+	}
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexzanda/pritunl-client/internal/mailer"
+)
+
+// SMTPConfig 邮件投递使用的smtp服务配置
+type SMTPConfig = mailer.Config
+
+// WebhookSink 通用的json webhook，请求体为Event的json。配置了Secret时，会对"时间戳.请求体"做HMAC-SHA256签名，
+// 签名放在X-Pritunl-Signature头中，格式为sha256=十六进制签名，时间戳放在X-Pritunl-Timestamp头中
+type WebhookSink struct {
+	URL        string
+	Secret     string
+	HTTPClient *http.Client // 为空时使用http.DefaultClient
+}
+
+// Name 投递目标名称
+func (s *WebhookSink) Name() string {
+	return "webhook:" + s.URL
+}
+
+// Send 投递事件
+func (s *WebhookSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if len(s.Secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(s.Secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		header.Set("X-Pritunl-Timestamp", timestamp)
+		header.Set("X-Pritunl-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return postJSON(ctx, s.HTTPClient, s.URL, body, header)
+}
+
+// SlackSink slack兼容的incoming webhook，钉钉、飞书等兼容slack格式的机器人同样适用
+type SlackSink struct {
+	WebhookURL string
+	HTTPClient *http.Client // 为空时使用http.DefaultClient
+}
+
+// Name 投递目标名称
+func (s *SlackSink) Name() string {
+	return "slack"
+}
+
+// Send 投递事件
+func (s *SlackSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("%s (%s)", event.Summary(), event.Time.Format(time.RFC3339)),
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.HTTPClient, s.WebhookURL, body, nil)
+}
+
+// EmailSink 邮件通知
+type EmailSink struct {
+	SMTP SMTPConfig
+	To   []string
+}
+
+// Name 投递目标名称
+func (s *EmailSink) Name() string {
+	return "email"
+}
+
+// Send 投递事件
+func (s *EmailSink) Send(ctx context.Context, event Event) error {
+	detail, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		return err
+	}
+	return mailer.SendContext(ctx, s.SMTP, mailer.Message{
+		To:      s.To,
+		Subject: "pritunl: " + event.Summary(),
+		Text:    fmt.Sprintf("%s\n\n%s\n", event.Summary(), detail),
+	})
+}
+
+// postJSON 发送json请求，响应状态码不是2xx时返回错误
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	content, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("resp status code is: %d, resp body: %s", resp.StatusCode, string(content))
+	}
+	return nil
+}
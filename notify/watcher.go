@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pritunl "github.com/alexzanda/pritunl-client"
+)
+
+// Watcher 定时轮询pritunl的server和用户状态，对比前后两次的差异生成事件
+type Watcher struct {
+	client *pritunl.Client
+	source string
+
+	initialized bool
+	servers     map[string]pritunl.VpnServer
+	users       map[string]userState
+}
+
+// userState 用户状态快照
+type userState struct {
+	user    pritunl.UserDetail
+	orgName string
+}
+
+// NewWatcher 创建轮询器，source为事件来源标识，比如实例名称
+func NewWatcher(client *pritunl.Client, source string) *Watcher {
+	return &Watcher{
+		client: client,
+		source: source,
+	}
+}
+
+// Poll 获取一次pritunl的状态并返回与上一次相比的变化事件，首次调用只记录状态，不产生事件
+func (w *Watcher) Poll(ctx context.Context) ([]Event, error) {
+	client := w.client.WithContext(ctx)
+	now := time.Now()
+
+	serverList, err := pritunl.GetServerList(client)
+	if err != nil {
+		return nil, fmt.Errorf("get server list failed, err: %w", err)
+	}
+	servers := map[string]pritunl.VpnServer{}
+	for _, server := range serverList {
+		servers[server.Id] = server
+	}
+
+	orgs, err := pritunl.GetOrganizationList(client)
+	if err != nil {
+		return nil, fmt.Errorf("get organizations failed, err: %w", err)
+	}
+	users := map[string]userState{}
+	for _, org := range orgs {
+		orgUsers, err := pritunl.GetUserList(client, org.Id)
+		if err != nil {
+			return nil, fmt.Errorf("get organization %s users failed, err: %w", org.Id, err)
+		}
+		for _, user := range orgUsers {
+			users[user.Id] = userState{user: user, orgName: org.Name}
+		}
+	}
+
+	var events []Event
+	if w.initialized {
+		events = append(w.diffServers(servers, now), w.diffUsers(users, now)...)
+	}
+	w.servers = servers
+	w.users = users
+	w.initialized = true
+	return events, nil
+}
+
+// diffServers 对比server状态
+func (w *Watcher) diffServers(servers map[string]pritunl.VpnServer, now time.Time) []Event {
+	var events []Event
+	newEvent := func(eventType EventType, server pritunl.VpnServer) Event {
+		return Event{Type: eventType, Time: now, Source: w.source, ServerId: server.Id, ServerName: server.Name}
+	}
+
+	for id, server := range servers {
+		old, ok := w.servers[id]
+		if !ok {
+			events = append(events, newEvent(EventServerCreated, server))
+			continue
+		}
+		if old.Status == server.Status {
+			continue
+		}
+		switch server.Status {
+		case "online":
+			events = append(events, newEvent(EventServerOnline, server))
+		case "offline":
+			events = append(events, newEvent(EventServerOffline, server))
+		}
+	}
+	for id, server := range w.servers {
+		if _, ok := servers[id]; !ok {
+			events = append(events, newEvent(EventServerDeleted, server))
+		}
+	}
+	return events
+}
+
+// diffUsers 对比用户状态
+func (w *Watcher) diffUsers(users map[string]userState, now time.Time) []Event {
+	var events []Event
+	newEvent := func(eventType EventType, state userState) Event {
+		return Event{
+			Type:             eventType,
+			Time:             now,
+			Source:           w.source,
+			OrganizationId:   state.user.Organization,
+			OrganizationName: state.orgName,
+			UserId:           state.user.Id,
+			UserName:         state.user.Name,
+		}
+	}
+
+	for id, state := range users {
+		old, ok := w.users[id]
+		if !ok {
+			events = append(events, newEvent(EventUserCreated, state))
+			continue
+		}
+		if old.user.Disabled == state.user.Disabled {
+			continue
+		}
+		if state.user.Disabled {
+			events = append(events, newEvent(EventUserDisabled, state))
+		} else {
+			events = append(events, newEvent(EventUserEnabled, state))
+		}
+	}
+	for id, state := range w.users {
+		if _, ok := users[id]; !ok {
+			events = append(events, newEvent(EventUserDeleted, state))
+		}
+	}
+	return events
+}
+
+// Run 按interval定时轮询，并将变化事件交给notifier投递，直到ctx取消。轮询或写入死信失败时调用onError(可为空)后继续
+func Run(ctx context.Context, watcher *Watcher, notifier *Notifier, interval time.Duration, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		events, err := watcher.Poll(ctx)
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+		} else if len(events) > 0 {
+			if err = notifier.Notify(ctx, events); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Package otelpritunl 为pritunl客户端提供OpenTelemetry链路追踪支持，每个http请求对应一个span，
+// InitVpnServer等高层操作及其每个步骤对应父span，并通过W3C trace context向下游传递追踪信息。
+//
+// 用法：
+//
+//	tracing := otelpritunl.New(nil, nil)
+//	tracing.Instrument(client)
+//	servers, err := pritunl.GetServerList(client.WithContext(ctx))
+package otelpritunl
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	pritunl "github.com/alexzanda/pritunl-client"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName 追踪器名称
+const instrumentationName = "github.com/alexzanda/pritunl-client/otelpritunl"
+
+// Tracing pritunl客户端的链路追踪
+type Tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// New 创建链路追踪，provider为空时使用otel全局的TracerProvider，propagator为空时使用W3C trace context
+func New(provider trace.TracerProvider, propagator propagation.TextMapPropagator) *Tracing {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+	return &Tracing{
+		tracer:     provider.Tracer(instrumentationName),
+		propagator: propagator,
+	}
+}
+
+// Instrument 为客户端添加请求追踪和操作追踪
+func (t *Tracing) Instrument(c *pritunl.Client) {
+	c.Use(t.Interceptor())
+	c.SetTracer(t)
+}
+
+// Start 开始一个高层操作的span，实现pritunl.Tracer
+func (t *Tracing) Start(ctx context.Context, operation string) (context.Context, func(err error)) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := t.tracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// Interceptor 返回请求追踪拦截器，每个请求创建一个client span，并将trace context注入请求头
+func (t *Tracing) Interceptor() pritunl.Interceptor {
+	return pritunl.Interceptor{
+		BeforeSend: func(req *http.Request) (*http.Request, error) {
+			route := pritunl.RouteTemplate(req.URL.Path)
+			ctx, _ := t.tracer.Start(req.Context(), req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("http.request.method", req.Method),
+					attribute.String("http.route", route),
+					attribute.String("server.address", req.URL.Hostname()),
+					attribute.String("url.full", req.URL.Redacted()),
+				),
+			)
+			req = req.WithContext(ctx)
+			t.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+			return req, nil
+		},
+		AfterReceive: func(req *http.Request, resp *http.Response, latency time.Duration, err error) {
+			span := trace.SpanFromContext(req.Context())
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			} else {
+				span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+				if resp.StatusCode >= http.StatusBadRequest {
+					span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+				}
+			}
+			span.End()
+		},
+	}
+}
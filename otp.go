@@ -0,0 +1,142 @@
+package pritunl
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// 双因素认证。server开启otp_auth后，用户连接时除了证书还需要输入otp验证码，验证码按RFC 6238由用户的otp密钥生成，
+// pritunl使用的参数为SHA1、6位数字、30秒一个周期
+
+const (
+	TOTPDigits = 6                // 验证码位数
+	TOTPPeriod = 30 * time.Second // 验证码有效周期
+)
+
+// SetServerOtpAuth 开启或关闭vpn server的otp认证，需要server处于停止状态
+func SetServerOtpAuth(c *Client, serverId string, enabled bool) (*VpnServer, error) {
+	return UpdateServer(c, serverId, ServerUpdateOpts{OtpAuth: Bool(enabled)})
+}
+
+// GetUserOtpSecret 获取用户的otp密钥
+func GetUserOtpSecret(c *Client, organizationId, userId string) (string, error) {
+	user, err := GetUser(c, organizationId, userId)
+	if err != nil {
+		return "", err
+	}
+	if len(user.OtpSecret) == 0 {
+		return "", fmt.Errorf("用户%s没有otp密钥", userId)
+	}
+	return user.OtpSecret, nil
+}
+
+// ResetUserOtpSecret 重新生成用户的otp密钥，旧密钥立即失效，返回包含新密钥的用户详情
+func ResetUserOtpSecret(c *Client, organizationId, userId string) (*UserDetail, error) {
+	var user UserDetail
+	opts := RequestOpts{
+		JSONResponse: &user,
+	}
+	if _, err := c.Request("put", getUserOtpSecretUrl(organizationId, userId), &opts); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// OtpProvisioningURI 生成otpauth://格式的密钥地址，可以生成二维码由google authenticator等应用扫描导入，
+// issuer一般为组织名称或者公司名称，account一般为用户名
+func OtpProvisioningURI(issuer, account, secret string) string {
+	label := account
+	if len(issuer) > 0 {
+		label = issuer + ":" + account
+	}
+	query := url.Values{}
+	query.Set("secret", secret)
+	if len(issuer) > 0 {
+		query.Set("issuer", issuer)
+	}
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// OtpQRCode 将密钥地址渲染为png格式的二维码，size为图片的边长，单位像素
+func OtpQRCode(uri string, size int) ([]byte, error) {
+	png, err := qrcode.Encode(uri, qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("generate qr code failed, err: %w", err)
+	}
+	return png, nil
+}
+
+// GenerateTOTP 按RFC 6238生成t时刻的验证码
+func GenerateTOTP(secret string, t time.Time) (string, error) {
+	key, err := decodeOtpSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return totpCode(key, t.Unix()/int64(TOTPPeriod/time.Second)), nil
+}
+
+// VerifyTOTP 校验验证码，skew为允许前后偏差的周期数，用于容忍客户端时钟误差，一般为1
+func VerifyTOTP(secret, code string, t time.Time, skew int) (bool, error) {
+	key, err := decodeOtpSecret(secret)
+	if err != nil {
+		return false, err
+	}
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return false, nil
+	}
+	counter := t.Unix() / int64(TOTPPeriod/time.Second)
+	for i := -skew; i <= skew; i++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, counter+int64(i))), []byte(code)) == 1 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// decodeOtpSecret 解码base32格式的密钥，忽略大小写、空格和填充
+func decodeOtpSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	if len(secret) == 0 {
+		return nil, errors.New("otp密钥不能为空")
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("decode otp secret failed, err: %w", err)
+	}
+	return key, nil
+}
+
+// totpCode 按RFC 4226计算counter对应的验证码
+func totpCode(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod)
+}
@@ -0,0 +1,119 @@
+package pritunl
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238附录B中SHA1测试向量使用的密钥"12345678901234567890"
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// RFC 6238附录B的SHA1测试向量，原文为8位验证码，pritunl使用6位，取其后6位
+func TestGenerateTOTPRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		got, err := GenerateTOTP(rfc6238Secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.code[len(tt.code)-TOTPDigits:]; got != want {
+			t.Errorf("GenerateTOTP(%d) = %s, want %s", tt.unix, got, want)
+		}
+	}
+}
+
+func TestVerifyTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := GenerateTOTP(rfc6238Secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		skew int
+		want bool
+	}{
+		{"same period", now, 0, true},
+		{"end of period", time.Unix(1111111139, 0), 0, true},
+		{"next period without skew", now.Add(TOTPPeriod), 0, false},
+		{"previous period without skew", now.Add(-TOTPPeriod), 0, false},
+		{"next period with skew", now.Add(TOTPPeriod), 1, true},
+		{"previous period with skew", now.Add(-TOTPPeriod), 1, true},
+		{"two periods later with skew 1", now.Add(2 * TOTPPeriod), 1, false},
+		{"two periods later with skew 2", now.Add(2 * TOTPPeriod), 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyTOTP(rfc6238Secret, code, tt.at, tt.skew)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyTOTP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		want   bool
+	}{
+		{"lower case secret with spaces", strings.ToLower(rfc6238Secret[:8] + " " + rfc6238Secret[8:]), "287082", true},
+		{"padded secret", rfc6238Secret + "====", "287082", true},
+		{"code with spaces", rfc6238Secret, " 287082 ", true},
+		{"wrong code", rfc6238Secret, "287083", false},
+		{"short code", rfc6238Secret, "28708", false},
+		{"eight digit code", rfc6238Secret, "94287082", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyTOTP(tt.secret, tt.code, now, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyTOTP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, secret := range []string{"", "not base32!"} {
+		if _, err := VerifyTOTP(secret, "287082", now, 0); err == nil {
+			t.Errorf("VerifyTOTP(%q) should fail", secret)
+		}
+	}
+}
+
+func TestOtpProvisioningURI(t *testing.T) {
+	uri := OtpProvisioningURI("Acme Corp", "alice", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Acme Corp:alice" {
+		t.Errorf("unexpected uri: %s", uri)
+	}
+	if u.Query().Get("secret") != "JBSWY3DPEHPK3PXP" || u.Query().Get("issuer") != "Acme Corp" {
+		t.Errorf("unexpected query: %s", u.RawQuery)
+	}
+}
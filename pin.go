@@ -0,0 +1,173 @@
+package pritunl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 用户pin和yubikey二次认证。pin在用户连接时与密码一起输入，是否必须由系统配置pin_mode决定；
+// yubikey需要在系统配置中启用yubico单点登录，用户绑定yubikey id后连接时需要按一下yubikey输入一次性密码。
+// pritunl中这两项要求对所有vpn server生效
+
+// PinMode 系统的pin模式
+type PinMode string
+
+const (
+	PinModeOptional PinMode = "optional" // 用户可以不设置pin
+	PinModeRequired PinMode = "required" // 用户必须设置pin才能连接
+	PinModeDisabled PinMode = "disabled" // 不使用pin
+)
+
+// yubikeyIdLength yubikey id的长度，即yubikey一次性密码的前12个字符
+const yubikeyIdLength = 12
+
+// PinPolicy 设置pin前在本地做的检查
+type PinPolicy struct {
+	MinLength    int  // 最短长度，小于等于0时不检查
+	MaxLength    int  // 最长长度，小于等于0时不检查
+	DigitsOnly   bool // 是否只能包含数字
+	RejectSimple bool // 是否拒绝全部相同或连续递增递减的简单pin，比如111111、123456
+}
+
+// DefaultPinPolicy 默认的pin检查策略，与pritunl默认只允许数字且至少6位的要求一致
+var DefaultPinPolicy = PinPolicy{
+	MinLength:    6,
+	DigitsOnly:   true,
+	RejectSimple: true,
+}
+
+// Check 检查pin是否满足策略
+func (p PinPolicy) Check(pin string) error {
+	if len(pin) == 0 {
+		return errors.New("pin不能为空")
+	}
+	if p.MinLength > 0 && len(pin) < p.MinLength {
+		return fmt.Errorf("pin长度不能少于%d位", p.MinLength)
+	}
+	if p.MaxLength > 0 && len(pin) > p.MaxLength {
+		return fmt.Errorf("pin长度不能超过%d位", p.MaxLength)
+	}
+	if p.DigitsOnly && strings.Trim(pin, "0123456789") != "" {
+		return errors.New("pin只能包含数字")
+	}
+	if p.RejectSimple && isSimplePin(pin) {
+		return errors.New("pin过于简单")
+	}
+	return nil
+}
+
+// isSimplePin 判断pin是否全部相同或者连续递增递减
+func isSimplePin(pin string) bool {
+	if len(pin) < 2 {
+		return true
+	}
+	same, ascending, descending := true, true, true
+	for i := 1; i < len(pin); i++ {
+		diff := int(pin[i]) - int(pin[i-1])
+		same = same && diff == 0
+		ascending = ascending && diff == 1
+		descending = descending && diff == -1
+	}
+	return same || ascending || descending
+}
+
+// SetUserPin 按policy检查后设置用户的pin
+func SetUserPin(c *Client, organizationId, userId, pin string, policy PinPolicy) (*UserDetail, error) {
+	if err := policy.Check(pin); err != nil {
+		return nil, err
+	}
+	return UpdateUser(c, organizationId, userId, UserModifyOpts{Pin: String(pin)})
+}
+
+// ClearUserPin 清除用户的pin
+func ClearUserPin(c *Client, organizationId, userId string) (*UserDetail, error) {
+	return UpdateUser(c, organizationId, userId, UserModifyOpts{Pin: String("")})
+}
+
+// SetUserYubikey 为用户绑定yubikey，yubikeyId可以是yubikey id或者按一下yubikey输出的一次性密码，
+// 一次性密码只取前12个字符作为id
+func SetUserYubikey(c *Client, organizationId, userId, yubikeyId string) (*UserDetail, error) {
+	yubikeyId = strings.ToLower(strings.TrimSpace(yubikeyId))
+	if len(yubikeyId) < yubikeyIdLength {
+		return nil, fmt.Errorf("yubikey id长度不能少于%d位", yubikeyIdLength)
+	}
+	yubikeyId = yubikeyId[:yubikeyIdLength]
+	// yubikey输出使用modhex编码，只包含cbdefghijklnrtuv
+	if strings.Trim(yubikeyId, "cbdefghijklnrtuv") != "" {
+		return nil, errors.New("yubikey id格式错误")
+	}
+	return UpdateUser(c, organizationId, userId, UserModifyOpts{YubicoId: String(yubikeyId)})
+}
+
+// ClearUserYubikey 解绑用户的yubikey
+func ClearUserYubikey(c *Client, organizationId, userId string) (*UserDetail, error) {
+	return UpdateUser(c, organizationId, userId, UserModifyOpts{YubicoId: String("")})
+}
+
+// SetUserSecondaryAuth 开启或关闭用户的二次认证(otp、yubikey等)，关闭后该用户连接时跳过二次认证
+func SetUserSecondaryAuth(c *Client, organizationId, userId string, enabled bool) (*UserDetail, error) {
+	return UpdateUser(c, organizationId, userId, UserModifyOpts{BypassSecondary: Bool(!enabled)})
+}
+
+// RequireUserPin 设置系统的pin模式
+func RequireUserPin(c *Client, mode PinMode) error {
+	switch mode {
+	case PinModeOptional, PinModeRequired, PinModeDisabled:
+	default:
+		return fmt.Errorf("不支持的pin模式: %s", mode)
+	}
+	_, err := UpdateSettings(c, Settings{PinMode: String(string(mode))})
+	return err
+}
+
+// yubicoSsoBases 可以与yubico组合的单点登录方式，组合后的取值为"方式_yubico"
+var yubicoSsoBases = map[string]bool{
+	"google":        true,
+	"slack":         true,
+	"saml":          true,
+	"saml_okta":     true,
+	"saml_onelogin": true,
+	"azure":         true,
+	"authzero":      true,
+}
+
+// mergeYubicoSso 计算在当前单点登录方式上启用yubico后的取值，当前方式不能与yubico组合时返回错误
+func mergeYubicoSso(current string) (string, error) {
+	switch {
+	case len(current) == 0:
+		return "yubico", nil
+	case current == "yubico" || strings.HasSuffix(current, "_yubico"):
+		return current, nil
+	case yubicoSsoBases[current]:
+		return current + "_yubico", nil
+	}
+	return "", fmt.Errorf("当前的单点登录方式%s不能与yubico组合", current)
+}
+
+// RequireYubikey 启用yubico单点登录，启用后绑定了yubikey的用户连接时需要yubikey认证。
+// clientId和secretKey在yubico官网申请。已经启用了google、saml等单点登录时，会与之组合为google_yubico等方式，
+// 不会替换原有的单点登录；原有方式不能与yubico组合时(如duo、radius)返回错误，不修改配置
+func RequireYubikey(c *Client, clientId, secretKey string) error {
+	if len(clientId) == 0 || len(secretKey) == 0 {
+		return errors.New("yubico client id和secret key不能为空")
+	}
+	settings, err := GetSettings(c)
+	if err != nil {
+		return fmt.Errorf("get settings failed, err: %w", err)
+	}
+	current := ""
+	if settings.Sso != nil {
+		current = *settings.Sso
+	}
+	sso, err := mergeYubicoSso(current)
+	if err != nil {
+		return err
+	}
+	_, err = UpdateSettings(c, Settings{
+		Sso:             String(sso),
+		SsoYubicoClient: String(clientId),
+		SsoYubicoSecret: String(secretKey),
+	})
+	return err
+}
@@ -0,0 +1,182 @@
+package pritunl
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// fakeUserServer 用户更新和系统配置接口的替身，记录收到的请求体
+type fakeUserServer struct {
+	mu       sync.Mutex
+	user     UserDetail
+	settings map[string]interface{}
+	updates  []map[string]interface{} // 收到的用户更新请求体
+	puts     []map[string]interface{} // 收到的系统配置更新请求体
+}
+
+func (f *fakeUserServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/user/org1/u1" && r.Method == http.MethodPut:
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.updates = append(f.updates, body)
+		if pin, ok := body["pin"].(string); ok {
+			f.user.Pin = len(pin) > 0
+		}
+		writeJSON(w, f.user)
+	case r.URL.Path == "/settings" && r.Method == http.MethodGet:
+		writeJSON(w, f.settings)
+	case r.URL.Path == "/settings" && r.Method == http.MethodPut:
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.puts = append(f.puts, body)
+		for key, value := range body {
+			f.settings[key] = value
+		}
+		writeJSON(w, f.settings)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakeUserServer(t *testing.T) (*fakeUserServer, *Client) {
+	fake := &fakeUserServer{
+		user:     UserDetail{Id: "u1", Name: "alice"},
+		settings: map[string]interface{}{},
+	}
+	return fake, newTestClient(t, fake)
+}
+
+func TestSetAndClearUserPin(t *testing.T) {
+	fake, client := newFakeUserServer(t)
+
+	user, err := SetUserPin(client, "org1", "u1", "284913", DefaultPinPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.Pin {
+		t.Errorf("user pin should be set: %+v", user)
+	}
+	if user, err = ClearUserPin(client, "org1", "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if user.Pin {
+		t.Errorf("user pin should be cleared: %+v", user)
+	}
+
+	if len(fake.updates) != 2 {
+		t.Fatalf("updates = %v, want 2", fake.updates)
+	}
+	if pin, ok := fake.updates[0]["pin"]; !ok || pin != "284913" || len(fake.updates[0]) != 1 {
+		t.Errorf("set pin body = %v", fake.updates[0])
+	}
+	if pin, ok := fake.updates[1]["pin"]; !ok || pin != "" || len(fake.updates[1]) != 1 {
+		t.Errorf("clear pin body = %v, want empty pin", fake.updates[1])
+	}
+}
+
+func TestSetUserPinRejectedByPolicy(t *testing.T) {
+	fake, client := newFakeUserServer(t)
+	for _, pin := range []string{"", "12345", "123456", "111111", "65432a"} {
+		if _, err := SetUserPin(client, "org1", "u1", pin, DefaultPinPolicy); err == nil {
+			t.Errorf("SetUserPin(%q) should fail", pin)
+		}
+	}
+	if len(fake.updates) != 0 {
+		t.Errorf("rejected pins should not be sent: %v", fake.updates)
+	}
+}
+
+func TestSetAndClearUserYubikey(t *testing.T) {
+	fake, client := newFakeUserServer(t)
+
+	// 按一下yubikey输出的一次性密码，前12位为id
+	if _, err := SetUserYubikey(client, "org1", "u1", " CCCCCCJLKHRT ubvgdhfnltjkehhghlhhcdtltfglnjtd "); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ClearUserYubikey(client, "org1", "u1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"cccccc", "cccccc123456"} {
+		if _, err := SetUserYubikey(client, "org1", "u1", id); err == nil {
+			t.Errorf("SetUserYubikey(%q) should fail", id)
+		}
+	}
+
+	if len(fake.updates) != 2 {
+		t.Fatalf("updates = %v, want 2", fake.updates)
+	}
+	if id := fake.updates[0]["yubico_id"]; id != "ccccccjlkhrt" {
+		t.Errorf("yubico_id = %v, want ccccccjlkhrt", id)
+	}
+	if id, ok := fake.updates[1]["yubico_id"]; !ok || id != "" {
+		t.Errorf("clear yubikey body = %v, want empty yubico_id", fake.updates[1])
+	}
+}
+
+func TestRequireUserPin(t *testing.T) {
+	fake, client := newFakeUserServer(t)
+	if err := RequireUserPin(client, PinModeRequired); err != nil {
+		t.Fatal(err)
+	}
+	if err := RequireUserPin(client, PinMode("always")); err == nil {
+		t.Error("unknown pin mode should fail")
+	}
+	if len(fake.puts) != 1 || fake.puts[0]["pin_mode"] != "required" {
+		t.Errorf("settings puts = %v", fake.puts)
+	}
+}
+
+func TestRequireYubikeyKeepsSsoProvider(t *testing.T) {
+	tests := []struct {
+		current string
+		want    string
+		fails   bool
+	}{
+		{current: "", want: "yubico"},
+		{current: "yubico", want: "yubico"},
+		{current: "google", want: "google_yubico"},
+		{current: "saml_okta", want: "saml_okta_yubico"},
+		{current: "saml_yubico", want: "saml_yubico"},
+		{current: "google_duo", fails: true},
+		{current: "radius", fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.current, func(t *testing.T) {
+			fake, client := newFakeUserServer(t)
+			if len(tt.current) > 0 {
+				fake.settings["sso"] = tt.current
+			}
+			err := RequireYubikey(client, "12345", "c2VjcmV0")
+			if tt.fails {
+				if err == nil {
+					t.Fatal("RequireYubikey should refuse to change the sso provider")
+				}
+				if len(fake.puts) != 0 {
+					t.Errorf("settings should not be updated: %v", fake.puts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fake.puts) != 1 {
+				t.Fatalf("settings puts = %v, want 1", fake.puts)
+			}
+			put := fake.puts[0]
+			if put["sso"] != tt.want || put["sso_yubico_client"] != "12345" || put["sso_yubico_secret"] != "c2VjcmV0" {
+				t.Errorf("settings put = %v, want sso %s", put, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,458 @@
+package pritunl
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 批量开户。按名单(csv或json)在对应组织下并发创建缺失的用户，导出每个用户的连接配置，
+// 打包成zip并生成清单，清单记录名单中每一行对应的用户id、配置文件和错误信息，失败的行可以通过Resume重新执行
+
+// RosterEntry 名单中的一行
+type RosterEntry struct {
+	Name         string   `json:"name"`
+	Email        string   `json:"email,omitempty"`
+	Organization string   `json:"organization"` // 组织名称或组织id
+	Groups       []string `json:"groups,omitempty"`
+}
+
+// ParseRosterCSV 解析csv格式的名单，第一行为表头，必须包含name和organization列，email和groups列可选，
+// groups列中的多个用户组以分号分隔
+func ParseRosterCSV(r io.Reader) ([]RosterEntry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read roster header failed, err: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"name", "organization"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("名单缺少%s列", name)
+		}
+	}
+	column := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var roster []RosterEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read roster failed, err: %w", err)
+		}
+		entry := RosterEntry{
+			Name:         column(record, "name"),
+			Email:        column(record, "email"),
+			Organization: column(record, "organization"),
+		}
+		for _, group := range strings.Split(column(record, "groups"), ";") {
+			if group = strings.TrimSpace(group); len(group) > 0 {
+				entry.Groups = append(entry.Groups, group)
+			}
+		}
+		roster = append(roster, entry)
+	}
+	return roster, nil
+}
+
+// ParseRosterJSON 解析json格式的名单，内容为RosterEntry数组
+func ParseRosterJSON(r io.Reader) ([]RosterEntry, error) {
+	var roster []RosterEntry
+	if err := json.NewDecoder(r).Decode(&roster); err != nil {
+		return nil, fmt.Errorf("parse roster failed, err: %w", err)
+	}
+	return roster, nil
+}
+
+// ProvisionOpts 批量开户选项
+type ProvisionOpts struct {
+	Concurrency  int    // 同时处理的用户数，小于等于0时使用DefaultFleetConcurrency
+	BundlePath   string // zip包路径，为空时不打包
+	ManifestPath string // 清单文件路径，为空时不写清单文件
+	Resume       bool   // 读取已有的清单和zip包，跳过已经成功的行，只重新执行失败的行，需要同时指定ManifestPath
+}
+
+// ManifestEntry 清单中的一行，与名单中的行一一对应
+type ManifestEntry struct {
+	Row            int      `json:"row"` // 名单中的行号，从1开始，不包括csv的表头
+	Name           string   `json:"name"`
+	Email          string   `json:"email,omitempty"`
+	Organization   string   `json:"organization"`
+	OrganizationId string   `json:"organizationId,omitempty"`
+	UserId         string   `json:"userId,omitempty"`
+	Created        bool     `json:"created"`            // 用户是否由本次开户创建，用户已存在时为false
+	Profiles       []string `json:"profiles,omitempty"` // 配置文件在zip包中的路径
+	Error          string   `json:"error,omitempty"`
+	DuplicateOf    int      `json:"duplicateOf,omitempty"` // 与前面某一行的组织和用户名相同时为该行的行号，结果与该行一致
+}
+
+// Manifest 开户清单
+type Manifest struct {
+	Time    time.Time       `json:"time"`
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// Failed 返回失败的行
+func (m *Manifest) Failed() []ManifestEntry {
+	var failed []ManifestEntry
+	for _, entry := range m.Entries {
+		if len(entry.Error) > 0 {
+			failed = append(failed, entry)
+		}
+	}
+	return failed
+}
+
+// LoadManifest 读取清单文件
+func LoadManifest(path string) (*Manifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err = json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest %s failed, err: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// ProvisionUsers 按名单批量开户。单行失败只记录在清单中，不影响其他行，只有获取组织列表、读写清单和zip包失败时返回错误。
+// 组织和用户名相同的行只处理第一行，后面的行在清单中复制第一行的结果
+func ProvisionUsers(ctx context.Context, c *Client, roster []RosterEntry, opts ProvisionOpts) (*Manifest, error) {
+	if opts.Resume && len(opts.ManifestPath) == 0 {
+		return nil, errors.New("resume需要指定清单文件路径")
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultFleetConcurrency
+	}
+	client := c.WithContext(ctx)
+
+	orgs, err := GetOrganizationList(client)
+	if err != nil {
+		return nil, fmt.Errorf("get organizations failed, err: %w", err)
+	}
+
+	previous, files, err := loadProvisionState(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &provisioner{
+		client: client,
+		orgs:   orgs,
+		users:  map[string]*orgUsers{},
+		files:  files,
+	}
+	manifest := &Manifest{Time: time.Now(), Entries: make([]ManifestEntry, len(roster))}
+	firstRows := map[string]int{} // 组织和用户名 -> 第一次出现的行的下标
+	duplicates := map[int]int{}   // 重复行的下标 -> 第一次出现的行的下标
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, entry := range roster {
+		row := i + 1
+		key := p.rosterKey(entry)
+		if first, ok := firstRows[key]; ok {
+			duplicates[i] = first
+			continue
+		}
+		firstRows[key] = i
+		if old, ok := previous[row]; ok && old.Name == entry.Name && old.Organization == entry.Organization {
+			manifest.Entries[i] = old
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i, row int, entry RosterEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			manifest.Entries[i] = p.provision(ctx, row, entry)
+		}(i, row, entry)
+	}
+	wg.Wait()
+	for i, first := range duplicates {
+		entry := manifest.Entries[first]
+		entry.Row = i + 1
+		entry.Email = roster[i].Email
+		entry.Created = false
+		entry.DuplicateOf = first + 1
+		manifest.Entries[i] = entry
+	}
+
+	if len(opts.BundlePath) > 0 {
+		if err = writeBundle(opts.BundlePath, manifest, p.files); err != nil {
+			return manifest, fmt.Errorf("write bundle %s failed, err: %w", opts.BundlePath, err)
+		}
+	}
+	if len(opts.ManifestPath) > 0 {
+		content, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return manifest, err
+		}
+		if err = os.WriteFile(opts.ManifestPath, content, 0600); err != nil {
+			return manifest, fmt.Errorf("write manifest %s failed, err: %w", opts.ManifestPath, err)
+		}
+	}
+	return manifest, nil
+}
+
+// loadProvisionState 读取上一次开户成功的行，以及这些行在zip包中的配置文件，只在Resume时读取
+func loadProvisionState(opts ProvisionOpts) (map[int]ManifestEntry, map[string][]byte, error) {
+	previous := map[int]ManifestEntry{}
+	files := map[string][]byte{}
+	if !opts.Resume {
+		return previous, files, nil
+	}
+
+	manifest, err := LoadManifest(opts.ManifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return previous, files, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var bundle *zip.ReadCloser
+	if len(opts.BundlePath) > 0 {
+		bundle, err = zip.OpenReader(opts.BundlePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("open bundle %s failed, err: %w", opts.BundlePath, err)
+		}
+		if bundle != nil {
+			defer bundle.Close()
+		}
+	}
+
+	for _, entry := range manifest.Entries {
+		if len(entry.Error) > 0 || entry.DuplicateOf > 0 {
+			continue
+		}
+		// 需要打包但旧zip包中缺少配置文件的行重新执行
+		complete := true
+		if len(opts.BundlePath) > 0 {
+			for _, name := range entry.Profiles {
+				content, err := readZipFile(bundle, name)
+				if err != nil {
+					complete = false
+					break
+				}
+				files[name] = content
+			}
+		}
+		if complete {
+			previous[entry.Row] = entry
+		}
+	}
+	return previous, files, nil
+}
+
+// readZipFile 读取zip包中的文件
+func readZipFile(bundle *zip.ReadCloser, name string) ([]byte, error) {
+	if bundle == nil {
+		return nil, os.ErrNotExist
+	}
+	f, err := bundle.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// provisioner 批量开户过程中共享的状态
+type provisioner struct {
+	client *Client
+	orgs   []Organization
+
+	mu    sync.Mutex
+	users map[string]*orgUsers // 组织id -> 组织下已有的用户
+	files map[string][]byte    // zip包中的路径 -> 配置文件内容
+}
+
+// orgUsers 组织下开户前已有的用户，每个组织只获取一次用户列表
+type orgUsers struct {
+	once sync.Once
+	ids  map[string]string // 用户名 -> 用户id
+	err  error
+}
+
+// provision 处理名单中的一行
+func (p *provisioner) provision(ctx context.Context, row int, entry RosterEntry) ManifestEntry {
+	result := ManifestEntry{
+		Row:          row,
+		Name:         entry.Name,
+		Email:        entry.Email,
+		Organization: entry.Organization,
+	}
+	fail := func(err error) ManifestEntry {
+		result.Error = err.Error()
+		return result
+	}
+	if ctx.Err() != nil {
+		return fail(ctx.Err())
+	}
+	if len(entry.Name) == 0 {
+		return fail(errors.New("用户名不能为空"))
+	}
+
+	org, ok := p.organization(entry.Organization)
+	if !ok {
+		return fail(fmt.Errorf("组织%s不存在", entry.Organization))
+	}
+	result.OrganizationId = org.Id
+
+	userId, err := p.userId(org.Id, entry.Name)
+	if err != nil {
+		return fail(fmt.Errorf("get organization users failed, err: %w", err))
+	}
+	if len(userId) == 0 {
+		users, err := AddUser(p.client, UserAddOpts{
+			Name:           entry.Name,
+			OrganizationId: org.Id,
+			Email:          entry.Email,
+			Groups:         entry.Groups,
+		})
+		if err != nil {
+			return fail(fmt.Errorf("add user failed, err: %w", err))
+		}
+		if len(users) == 0 {
+			return fail(errors.New("add user failed, empty response"))
+		}
+		userId = users[0].Id
+		result.Created = true
+	}
+	result.UserId = userId
+
+	profiles, err := ExportUserConnectFiles(p.client, org.Id, userId)
+	if err != nil {
+		return fail(fmt.Errorf("export connection files failed, err: %w", err))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, profile := range profiles {
+		name := bundleName(org.Name) + "/" + bundleName(entry.Name) + "/" + bundleName(profile.Name)
+		p.files[name] = profile.Content
+		result.Profiles = append(result.Profiles, name)
+	}
+	return result
+}
+
+// organization 按名称或id查找组织
+func (p *provisioner) organization(nameOrId string) (Organization, bool) {
+	for _, org := range p.orgs {
+		if org.Id == nameOrId || org.Name == nameOrId {
+			return org, true
+		}
+	}
+	return Organization{}, false
+}
+
+// rosterKey 名单中一行的去重键，由组织id(组织不存在时为名单中的组织)和用户名组成
+func (p *provisioner) rosterKey(entry RosterEntry) string {
+	organization := entry.Organization
+	if org, ok := p.organization(entry.Organization); ok {
+		organization = org.Id
+	}
+	return organization + "/" + entry.Name
+}
+
+// userId 查找组织下同名用户的id，用户不存在时返回空字符串。每个组织的用户列表只获取一次，
+// 获取期间不持有p.mu，不同组织的获取可以并发进行
+func (p *provisioner) userId(organizationId, name string) (string, error) {
+	p.mu.Lock()
+	users, ok := p.users[organizationId]
+	if !ok {
+		users = &orgUsers{}
+		p.users[organizationId] = users
+	}
+	p.mu.Unlock()
+
+	users.once.Do(func() {
+		list, err := GetUserList(p.client, organizationId)
+		if err != nil {
+			users.err = err
+			return
+		}
+		users.ids = map[string]string{}
+		for _, user := range list {
+			users.ids[user.Name] = user.Id
+		}
+	})
+	if users.err != nil {
+		return "", users.err
+	}
+	// 名单已去重，开户过程中不会再修改ids，可以不加锁读取
+	return users.ids[name], nil
+}
+
+// bundleName 替换名称中的路径分隔符，避免在zip包中产生额外的目录
+func bundleName(name string) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+}
+
+// writeBundle 将清单中所有成功行的配置文件和清单本身写入zip包，先写临时文件再重命名
+func writeBundle(path string, manifest *Manifest, files map[string][]byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	w := zip.NewWriter(f)
+	write := func(name string, content []byte) error {
+		fw, err := w.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(content)
+		return err
+	}
+	for _, entry := range manifest.Entries {
+		// 重复行与首次出现的行共用配置文件，只打包一次
+		if entry.DuplicateOf > 0 {
+			continue
+		}
+		for _, name := range entry.Profiles {
+			if err = write(name, files[name]); err != nil {
+				f.Close()
+				return err
+			}
+		}
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = write("manifest.json", content)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
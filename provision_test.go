@@ -0,0 +1,162 @@
+package pritunl
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeProvisionServer 开户用到的组织、用户和导出接口的替身
+type fakeProvisionServer struct {
+	t          *testing.T
+	mu         sync.Mutex
+	users      map[string][]UserDetail // 组织id -> 用户
+	adds       map[string]int          // 用户名 -> 创建次数
+	failExport map[string]bool         // 导出失败的用户名
+}
+
+func newFakeProvisionServer(t *testing.T) *fakeProvisionServer {
+	return &fakeProvisionServer{
+		t:          t,
+		users:      map[string][]UserDetail{"org1": {{Id: "u-existing", Name: "alice"}}},
+		adds:       map[string]int{},
+		failExport: map[string]bool{},
+	}
+}
+
+func (f *fakeProvisionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/organization":
+		writeJSON(w, []Organization{{Id: "org1", Name: "dev"}})
+	case len(parts) == 2 && parts[0] == "user" && r.Method == http.MethodGet:
+		writeJSON(w, f.users[parts[1]])
+	case len(parts) == 2 && parts[0] == "user" && r.Method == http.MethodPost:
+		var opts UserAddOpts
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.adds[opts.Name]++
+		user := UserDetail{Id: "u-" + opts.Name, Name: opts.Name}
+		f.users[parts[1]] = append(f.users[parts[1]], user)
+		writeJSON(w, []UserDetail{user})
+	case len(parts) == 3 && parts[0] == "key":
+		userId := strings.TrimSuffix(parts[2], ".tar")
+		if f.failExport[strings.TrimPrefix(userId, "u-")] {
+			http.Error(w, "export failed", http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(tarball(f.t, map[string]string{userId + ".ovpn": "profile of " + userId}))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestProvisionUsersDeduplicatesRows(t *testing.T) {
+	fake := newFakeProvisionServer(t)
+	client := newTestClient(t, fake)
+
+	roster := []RosterEntry{
+		{Name: "bob", Organization: "dev"},
+		{Name: "alice", Organization: "dev"},
+		{Name: "bob", Organization: "org1"},
+		{Name: "bob", Organization: "dev"},
+	}
+	bundlePath := filepath.Join(t.TempDir(), "profiles.zip")
+	manifest, err := ProvisionUsers(context.Background(), client, roster, ProvisionOpts{Concurrency: 4, BundlePath: bundlePath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.adds["bob"] != 1 || fake.adds["alice"] != 0 {
+		t.Errorf("adds = %v, want bob created once and alice reused", fake.adds)
+	}
+
+	first := manifest.Entries[0]
+	if !first.Created || first.UserId != "u-bob" || len(first.Profiles) != 1 {
+		t.Errorf("unexpected first entry: %+v", first)
+	}
+	for _, i := range []int{2, 3} {
+		entry := manifest.Entries[i]
+		if entry.Row != i+1 || entry.DuplicateOf != 1 || entry.Created || entry.UserId != "u-bob" ||
+			fmt.Sprint(entry.Profiles) != fmt.Sprint(first.Profiles) {
+			t.Errorf("unexpected duplicate entry %d: %+v", i, entry)
+		}
+	}
+	if alice := manifest.Entries[1]; alice.Created || alice.UserId != "u-existing" {
+		t.Errorf("unexpected existing user entry: %+v", alice)
+	}
+
+	// 重复行的配置文件只打包一次
+	bundle, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bundle.Close()
+	names := map[string]int{}
+	for _, f := range bundle.File {
+		names[f.Name]++
+	}
+	for name, n := range names {
+		if n != 1 {
+			t.Errorf("zip entry %s written %d times", name, n)
+		}
+	}
+	if names[first.Profiles[0]] != 1 || names["manifest.json"] != 1 {
+		t.Errorf("zip entries = %v, want bob's profile and the manifest", names)
+	}
+}
+
+func TestProvisionUsersResume(t *testing.T) {
+	fake := newFakeProvisionServer(t)
+	fake.failExport["carol"] = true
+	client := newTestClient(t, fake)
+
+	dir := t.TempDir()
+	opts := ProvisionOpts{
+		BundlePath:   filepath.Join(dir, "bundle.zip"),
+		ManifestPath: filepath.Join(dir, "manifest.json"),
+	}
+	roster := []RosterEntry{{Name: "bob", Organization: "dev"}, {Name: "carol", Organization: "dev"}}
+	manifest, err := ProvisionUsers(context.Background(), client, roster, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := manifest.Failed(); len(failed) != 1 || failed[0].Name != "carol" {
+		t.Fatalf("failed = %+v, want carol", failed)
+	}
+
+	fake.mu.Lock()
+	fake.failExport["carol"] = false
+	fake.mu.Unlock()
+	opts.Resume = true
+	manifest, err = ProvisionUsers(context.Background(), client, roster, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := manifest.Failed(); len(failed) != 0 {
+		t.Fatalf("failed after resume = %+v", failed)
+	}
+	if !manifest.Entries[0].Created {
+		t.Errorf("resumed run should keep the previous result of bob: %+v", manifest.Entries[0])
+	}
+	if fake.adds["bob"] != 1 || fake.adds["carol"] != 1 {
+		t.Errorf("adds = %v, want every user created once", fake.adds)
+	}
+}
+
+func TestProvisionUsersResumeRequiresManifest(t *testing.T) {
+	client := newTestClient(t, newFakeProvisionServer(t))
+	_, err := ProvisionUsers(context.Background(), client, nil, ProvisionOpts{Resume: true})
+	if err == nil {
+		t.Fatal("resume without manifest path should fail")
+	}
+}
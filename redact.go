@@ -0,0 +1,104 @@
+package pritunl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue 敏感信息被脱敏后的占位内容
+const RedactedValue = "******"
+
+// sensitiveHeaders 需要脱敏的请求头和响应头
+var sensitiveHeaders = []string{
+	"Auth-Token",
+	"Auth-Signature",
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"Csrf-Token",
+}
+
+// sensitiveJSONKeys 需要脱敏的json字段，如UpdateAdminUserAuthConfig返回的token和secret、组织的auth_secret等
+var sensitiveJSONKeys = map[string]bool{
+	"token":          true,
+	"secret":         true,
+	"auth_token":     true,
+	"auth_secret":    true,
+	"password":       true,
+	"otp_secret":     true,
+	"pin":            true,
+	"server_key":     true,
+	"email_password": true,
+	"sso_secret":     true,
+	"sso_token":      true,
+	"uri":            true,
+	"conf":           true,
+}
+
+// connectionFileSecrets 连接配置文件中的私钥类内容，包括openvpn内联的私钥、tls认证key以及pem格式的私钥
+var connectionFileSecrets = []*regexp.Regexp{
+	regexp.MustCompile(`(?s)(<key>).*?(</key>)`),
+	regexp.MustCompile(`(?s)(<tls-auth>).*?(</tls-auth>)`),
+	regexp.MustCompile(`(?s)(<tls-crypt>).*?(</tls-crypt>)`),
+	regexp.MustCompile(`(?s)(-----BEGIN [A-Z ]*PRIVATE KEY-----).*?(-----END [A-Z ]*PRIVATE KEY-----)`),
+	regexp.MustCompile(`(?m)^((?:PrivateKey|PresharedKey)\s*=\s*)\S+()$`),
+}
+
+// RedactHeader 返回脱敏后的http头副本，认证相关的头会被替换为RedactedValue
+func RedactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, key := range sensitiveHeaders {
+		if redacted.Get(key) != "" {
+			redacted.Set(key, RedactedValue)
+		}
+	}
+	return redacted
+}
+
+// RedactConnectionFile 返回脱敏后的连接配置文件内容，私钥和tls key会被替换为RedactedValue
+func RedactConnectionFile(content []byte) []byte {
+	for _, re := range connectionFileSecrets {
+		content = re.ReplaceAll(content, []byte("${1}"+RedactedValue+"${2}"))
+	}
+	return content
+}
+
+// RedactBody 返回脱敏后的请求体或响应体，json内容会脱敏敏感字段，其他内容按连接配置文件处理
+func RedactBody(body []byte) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		var data interface{}
+		if err := json.Unmarshal(trimmed, &data); err == nil {
+			if rendered, err := json.Marshal(redactJSONValue(data)); err == nil {
+				return rendered
+			}
+		}
+	}
+	return RedactConnectionFile(body)
+}
+
+// redactJSONValue 递归脱敏json中的敏感字段
+func redactJSONValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if sensitiveJSONKeys[strings.ToLower(key)] {
+				if _, ok := item.(bool); !ok && item != nil {
+					value[key] = RedactedValue
+				}
+				continue
+			}
+			value[key] = redactJSONValue(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactJSONValue(item)
+		}
+	case string:
+		return string(RedactConnectionFile([]byte(value)))
+	}
+	return v
+}
@@ -6,18 +6,22 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 )
 
-// UpdatePublicAccessAddress 更新系统对外提供的公网地址，这个地址会被客户端连接配置文件使用，只需要服务端返回200即可
+// UpdatePublicAccessAddress 更新系统对外提供的公网地址，这个地址会被客户端连接配置文件使用，只需要服务端返回200即可。
+// 地址可以是ipv4、ipv6地址或者域名，ipv6地址会更新到public_address6，其他更新到public_address
 func UpdatePublicAccessAddress(c *Client, newAddress string) (*http.Response, error) {
-	if net.ParseIP(newAddress) == nil {
+	if !isValidPublicAddress(newAddress) {
 		return nil, errors.New("地址格式不合法")
 	}
+	key := "public_address"
+	if isIPv6Address(newAddress) {
+		key = "public_address6"
+	}
 	opts := RequestOpts{
 		JSONBody: map[string]string{
-			"public_address": newAddress,
+			key: newAddress,
 		},
 	}
 	return c.Request("put", getServerSettingsPath(), &opts)
@@ -64,16 +68,28 @@ func UpdateAdminUserAuthConfig(c *Client, adminUser AdminUser) (*AdminUser, erro
 
 // VpnServer vpn server实例配置
 type VpnServer struct {
-	Name           string `json:"name,omitempty"`    // 不给的话由本包自动生成
-	Id             string `json:"id,omitempty"`      // 创建server时不需要传递此参数
-	Network        string `json:"network,omitempty"` // 不给的话由本包自动生成一个，必须满足[10,172,192].[0-255,16-31,168].[0-255].0/[8-24]
-	Port           int    `json:"port,omitempty"`
-	Protocol       string `json:"protocol,omitempty"` // 不给的话默认为udp
-	Cipher         string `json:"cipher,omitempty"`   // 不给的话默认为aes128
-	Hash           string `json:"hash,omitempty"`     // 不给的话默认为sha1
-	RestrictRoutes bool   `json:"restrict_routes,omitempty"`
-	NetworkMode    string `json:"network_mode,omitempty"` // 不给的话默认为tunnel
-	Status         string `json:"status,omitempty"`       // 服务的状态
+	Name           string         `json:"name,omitempty"`    // 不给的话由本包自动生成
+	Id             string         `json:"id,omitempty"`      // 创建server时不需要传递此参数
+	Network        string         `json:"network,omitempty"` // 不给的话由本包自动生成一个，必须满足[10,172,192].[0-255,16-31,168].[0-255].0/[8-24]
+	Port           int            `json:"port,omitempty"`
+	Protocol       ServerProtocol `json:"protocol,omitempty"` // 不给的话默认为udp
+	Cipher         ServerCipher   `json:"cipher,omitempty"`   // 不给的话默认为aes128
+	Hash           ServerHash     `json:"hash,omitempty"`     // 不给的话默认为sha1
+	RestrictRoutes bool           `json:"restrict_routes,omitempty"`
+	NetworkMode    NetworkMode    `json:"network_mode,omitempty"`   // 不给的话默认为tunnel
+	Status         string         `json:"status,omitempty"`         // 服务的状态
+	Uptime         int            `json:"uptime,omitempty"`         // 服务运行时长，单位秒，只读
+	UsersOnline    int            `json:"users_online,omitempty"`   // 在线用户数，只读
+	DevicesOnline  int            `json:"devices_online,omitempty"` // 在线设备数，只读
+	UserCount      int            `json:"user_count,omitempty"`     // 可连接该服务的用户总数，只读
+	OtpAuth        bool           `json:"otp_auth,omitempty"`       // 连接时是否需要校验用户的otp验证码
+	Groups         []string       `json:"groups,omitempty"`         // 允许连接的用户组，为空时组织下所有用户都可以连接
+	Wg             bool           `json:"wg,omitempty"`             // 是否同时运行wireguard
+	PortWg         int            `json:"port_wg,omitempty"`        // wireguard端口，udp协议
+	NetworkWg      string         `json:"network_wg,omitempty"`     // wireguard网段，不能与Network重叠
+	Ipv6           bool           `json:"ipv6,omitempty"`           // 是否启用ipv6
+	Network6       string         `json:"network6,omitempty"`       // ipv6网段，不给的话由服务端自动生成
+	Ipv6Firewall   bool           `json:"ipv6_firewall,omitempty"`  // 是否阻止外部主动访问客户端的ipv6地址
 }
 
 // CreateVpnServer 创建一个新的vpn server, 返回值是ServerCreateConfig
@@ -85,16 +101,23 @@ func CreateVpnServer(c *Client, server VpnServer) (*VpnServer, error) {
 		server.Network = "10.12.12.0/24"
 	}
 	if len(server.Protocol) == 0 {
-		server.Protocol = "udp"
+		server.Protocol = ProtocolUdp
 	}
 	if len(server.Cipher) == 0 {
-		server.Cipher = "aes128"
+		server.Cipher = CipherAes128
 	}
 	if len(server.Hash) == 0 {
-		server.Hash = "sha1"
+		server.Hash = HashSha1
 	}
 	if len(server.NetworkMode) == 0 {
-		server.NetworkMode = "tunnel"
+		server.NetworkMode = NetworkModeTunnel
+	}
+
+	if len(server.Network6) > 0 {
+		server.Ipv6 = true
+	}
+	if err := server.Validate(); err != nil {
+		return nil, err
 	}
 
 	opts := RequestOpts{
@@ -107,6 +130,86 @@ func CreateVpnServer(c *Client, server VpnServer) (*VpnServer, error) {
 	return &server, nil
 }
 
+// GetServerList 获取vpn server列表
+func GetServerList(c *Client) ([]VpnServer, error) {
+	var servers []VpnServer
+	opts := RequestOpts{
+		JSONResponse: &servers,
+	}
+	if _, err := c.Request("get", getServerListPath(), &opts); err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+// GetServer 获取指定的vpn server
+func GetServer(c *Client, serverId string) (*VpnServer, error) {
+	var server VpnServer
+	opts := RequestOpts{
+		JSONResponse: &server,
+	}
+	if _, err := c.Request("get", getServerUrl(serverId), &opts); err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+const (
+	BandwidthPeriod1m  = "1m"  // 最近一段时间，按分钟统计
+	BandwidthPeriod5m  = "5m"  // 按5分钟统计
+	BandwidthPeriod30m = "30m" // 按30分钟统计
+	BandwidthPeriod2h  = "2h"  // 按2小时统计
+	BandwidthPeriod1d  = "1d"  // 按天统计
+)
+
+// ServerBandwidth vpn server的带宽统计，Received和Sent中每一项为[时间戳, 字节数]
+type ServerBandwidth struct {
+	Received      [][2]float64 `json:"received"`
+	ReceivedTotal float64      `json:"received_total"` // 统计周期内接收的总字节数
+	Sent          [][2]float64 `json:"sent"`
+	SentTotal     float64      `json:"sent_total"` // 统计周期内发送的总字节数
+}
+
+// GetServerBandwidth 获取vpn server在指定统计周期的带宽数据，period取值见BandwidthPeriod开头的常量
+func GetServerBandwidth(c *Client, serverId, period string) (*ServerBandwidth, error) {
+	var bandwidth ServerBandwidth
+	opts := RequestOpts{
+		JSONResponse: &bandwidth,
+	}
+	if _, err := c.Request("get", getServerBandwidthUrl(serverId, period), &opts); err != nil {
+		return nil, err
+	}
+	return &bandwidth, nil
+}
+
+// ServerUpdateOpts vpn server部分更新的配置，为nil的字段不修改。大部分配置需要server处于停止状态才能修改
+type ServerUpdateOpts struct {
+	OtpAuth      *bool     `json:"otp_auth,omitempty"`
+	Groups       *[]string `json:"groups,omitempty"` // 为空数组时取消用户组限制
+	Wg           *bool     `json:"wg,omitempty"`
+	PortWg       *int      `json:"port_wg,omitempty"`
+	NetworkWg    *string   `json:"network_wg,omitempty"`
+	Ipv6         *bool     `json:"ipv6,omitempty"`
+	Network6     *string   `json:"network6,omitempty"`
+	Ipv6Firewall *bool     `json:"ipv6_firewall,omitempty"`
+}
+
+// UpdateServer 更新vpn server的配置
+func UpdateServer(c *Client, serverId string, update ServerUpdateOpts) (*VpnServer, error) {
+	if err := update.Validate(); err != nil {
+		return nil, err
+	}
+	var server VpnServer
+	opts := RequestOpts{
+		JSONBody:     update,
+		JSONResponse: &server,
+	}
+	if _, err := c.Request("put", getServerUrl(serverId), &opts); err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
 // StartStopServer 启动或者停止vpn server
 func StartStopServer(c *Client, serverId string, start bool) (*VpnServer, error) {
 	var server VpnServer
@@ -117,7 +220,8 @@ func StartStopServer(c *Client, serverId string, start bool) (*VpnServer, error)
 	if !start {
 		operation = "stop"
 	}
-	if _, err := c.Request("put", getServerStartStopUrl(serverId, operation), &opts); err != nil {
+	path := c.endpointPath(EndpointServerOperation, getServerStartStopUrl(serverId, operation), serverId, operation)
+	if _, err := c.Request("put", path, &opts); err != nil {
 		return nil, err
 	}
 	return &server, nil
@@ -152,6 +256,18 @@ type AttachConf struct {
 	Name   string `json:"name,omitempty"` // 组织名称
 }
 
+// GetServerOrganizations 获取server已添加的组织
+func GetServerOrganizations(c *Client, serverId string) ([]AttachConf, error) {
+	var orgs []AttachConf
+	opts := RequestOpts{
+		JSONResponse: &orgs,
+	}
+	if _, err := c.Request("get", getServerOrganizationsUrl(serverId), &opts); err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
 // AttachOrganizationToServer 为server添加一个组织，一个在pritunl中，一个vpn server必须要属于某个组织
 func AttachOrganizationToServer(c *Client, conf AttachConf) (*AttachConf, error) {
 	opts := RequestOpts{
@@ -176,7 +292,7 @@ type RouteDetail struct {
 type RouteAddOpts struct {
 	Id      string `json:"id,omitempty"`
 	Server  string `json:"server"`  // vpn server id
-	Network string `json:"network"` // 路由的网段
+	Network string `json:"network"` // 路由的网段，ipv4或ipv6的cidr格式
 	Nat     bool   `json:"nat"`     // 针对此网段是否采用nat模式，否则就是路由模式
 }
 
@@ -200,8 +316,11 @@ func DeleteRoute(c *Client, serverId, routeId string) error {
 	return nil
 }
 
-// AddRoute 添加路由
+// AddRoute 添加路由，ipv6网段需要server启用ipv6
 func AddRoute(c *Client, route RouteAddOpts) (*RouteDetail, error) {
+	if err := route.Validate(); err != nil {
+		return nil, err
+	}
 	var routeDetail RouteDetail
 	opts := RequestOpts{
 		JSONBody:     route,
@@ -223,6 +342,9 @@ type RouteUpdateOpts struct {
 
 // UpdateRoute 更新路由配置
 func UpdateRoute(c *Client, route RouteUpdateOpts) (*RouteDetail, error) {
+	if err := route.Validate(); err != nil {
+		return nil, err
+	}
 	var routeDetail RouteDetail
 	opts := RequestOpts{
 		JSONBody:     route,
@@ -236,28 +358,71 @@ func UpdateRoute(c *Client, route RouteUpdateOpts) (*RouteDetail, error) {
 
 // UserDetail 用户详情
 type UserDetail struct {
-	Id               string `json:"id"`
-	Organization     string `json:"organization"`
-	OrganizationName string `json:"organization_name"`
-	Name             string `json:"name"`
-	Email            string `json:"email"`
-	Disabled         bool   `json:"disabled"` // 是否被禁用
+	Id               string        `json:"id"`
+	Organization     string        `json:"organization"`
+	OrganizationName string        `json:"organization_name"`
+	Name             string        `json:"name"`
+	Email            string        `json:"email"`
+	Disabled         bool          `json:"disabled"`         // 是否被禁用
+	OtpAuth          bool          `json:"otp_auth"`         // 用户所在的server是否开启了otp认证
+	OtpSecret        string        `json:"otp_secret"`       // otp密钥，base32编码
+	Pin              bool          `json:"pin"`              // 是否设置了pin，只读
+	YubicoId         string        `json:"yubico_id"`        // 绑定的yubikey id
+	BypassSecondary  bool          `json:"bypass_secondary"` // 是否跳过otp、yubikey等二次认证
+	Groups           []string      `json:"groups"`           // 用户所属的用户组
+	PortForwarding   []PortForward `json:"port_forwarding"`  // 端口转发规则
+	DnsMapping       bool          `json:"dns_mapping"`      // 是否为用户添加dns记录
+	DnsServers       []string      `json:"dns_servers"`      // 用户专属的dns服务器
+	DnsSuffix        string        `json:"dns_suffix"`       // 用户专属的dns搜索域
+	NetworkLinks     []string      `json:"network_links"`    // 用户身后的网段，cidr格式，其他用户可以经由该用户访问
+	ClientToClient   bool          `json:"client_to_client"` // 是否允许与其他用户直接互访
+	Servers          []UserServer  `json:"servers"`          // 用户在各个server上的地址和连接状态，只有用户列表中返回
+}
+
+// GetUserList 获取组织下的用户列表
+func GetUserList(c *Client, organizationId string) ([]UserDetail, error) {
+	var users []UserDetail
+	opts := RequestOpts{
+		JSONResponse: &users,
+	}
+	if _, err := c.resourceRequest("get", getUserListUrl(organizationId), ResourceUser, &opts); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUser 获取用户详情
+func GetUser(c *Client, organizationId, userId string) (*UserDetail, error) {
+	var user UserDetail
+	opts := RequestOpts{
+		JSONResponse: &user,
+	}
+	if _, err := c.resourceRequest("get", getUserUrl(organizationId, userId), ResourceUser, &opts); err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
 // UserAddOpts 用户添加配置
 type UserAddOpts struct {
-	Name           string `json:"name"`
-	OrganizationId string `json:"organizationId"`
+	Name           string   `json:"name"`
+	OrganizationId string   `json:"organizationId"`
+	Email          string   `json:"email,omitempty"`    // 用户邮箱
+	Groups         []string `json:"groups,omitempty"`   // 用户所属的用户组
+	Disabled       bool     `json:"disabled,omitempty"` // 是否创建为禁用状态
 }
 
 // AddUser 向组织添加用户
 func AddUser(c *Client, user UserAddOpts) ([]UserDetail, error) {
+	if err := user.Validate(); err != nil {
+		return nil, err
+	}
 	var users []UserDetail
 	opts := RequestOpts{
 		JSONBody:     user,
 		JSONResponse: &users,
 	}
-	if _, err := c.Request("post", getAddUserUrl(user.OrganizationId), &opts); err != nil {
+	if _, err := c.resourceRequest("post", getAddUserUrl(user.OrganizationId), ResourceUser, &opts); err != nil {
 		return nil, err
 	}
 	return users, nil
@@ -277,12 +442,53 @@ func EnableDisableUser(c *Client, conf UserUpdateOpts) (*UserDetail, error) {
 		JSONBody:     map[string]bool{"disabled": conf.Disabled},
 		JSONResponse: &userDetail,
 	}
-	if _, err := c.Request("put", getUpdateUserUrl(conf.OrganizationId, conf.UserId), &opts); err != nil {
+	if _, err := c.resourceRequest("put", getUpdateUserUrl(conf.OrganizationId, conf.UserId), ResourceUser, &opts); err != nil {
+		return nil, err
+	}
+	return &userDetail, nil
+}
+
+// UserModifyOpts 用户部分更新的配置，为nil的字段不修改
+type UserModifyOpts struct {
+	Name            *string        `json:"name,omitempty"`
+	Email           *string        `json:"email,omitempty"`
+	Disabled        *bool          `json:"disabled,omitempty"`
+	Pin             *string        `json:"pin,omitempty"`       // 为空字符串时清除pin
+	YubicoId        *string        `json:"yubico_id,omitempty"` // 为空字符串时解绑yubikey
+	BypassSecondary *bool          `json:"bypass_secondary,omitempty"`
+	Groups          *[]string      `json:"groups,omitempty"` // 为空数组时清空用户组
+	PortForwarding  *[]PortForward `json:"port_forwarding,omitempty"`
+	DnsMapping      *bool          `json:"dns_mapping,omitempty"`
+	DnsServers      *[]string      `json:"dns_servers,omitempty"`
+	DnsSuffix       *string        `json:"dns_suffix,omitempty"`
+	NetworkLinks    *[]string      `json:"network_links,omitempty"`
+	ClientToClient  *bool          `json:"client_to_client,omitempty"`
+}
+
+// UpdateUser 部分更新用户，提交前会校验用户名、邮箱、端口转发、dns、网段等配置的格式
+func UpdateUser(c *Client, organizationId, userId string, update UserModifyOpts) (*UserDetail, error) {
+	if err := update.Validate(); err != nil {
+		return nil, err
+	}
+	var userDetail UserDetail
+	opts := RequestOpts{
+		JSONBody:     update,
+		JSONResponse: &userDetail,
+	}
+	if _, err := c.resourceRequest("put", getUpdateUserUrl(organizationId, userId), ResourceUser, &opts); err != nil {
 		return nil, err
 	}
 	return &userDetail, nil
 }
 
+// DeleteUser 删除用户
+func DeleteUser(c *Client, organizationId, userId string) error {
+	if _, err := c.Request("delete", getDeleteUserUrl(organizationId, userId), nil); err != nil {
+		return err
+	}
+	return nil
+}
+
 // ConnectionFile 连接配置文件
 type ConnectionFile struct {
 	Name    string `json:"name"`    // 连接文件名，以.ovpn为后缀，可直接在openvpn客户端导入
@@ -291,6 +497,19 @@ type ConnectionFile struct {
 
 // ExportUserConnectFile 导出用户的连接配置, 导出的是配置文件的tar包的内容，需要自己按需获取解压内容
 func ExportUserConnectFile(c *Client, organizationId, userId string) (*ConnectionFile, error) {
+	files, err := ExportUserConnectFiles(c, organizationId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("export connection config file failed")
+	}
+
+	return &files[0], nil
+}
+
+// ExportUserConnectFiles 导出用户的所有连接配置，用户所在组织关联了多个server时，每个server对应一个配置文件
+func ExportUserConnectFiles(c *Client, organizationId, userId string) ([]ConnectionFile, error) {
 	opts := RequestOpts{
 		KeepResponseBody: true,
 	}
@@ -301,15 +520,7 @@ func ExportUserConnectFile(c *Client, organizationId, userId string) (*Connectio
 	defer resp.Body.Close()
 
 	// 解压压缩包
-	files, err := extractTar(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if len(files) != 1 {
-		return nil, fmt.Errorf("export connection config file failed")
-	}
-
-	return &files[0], nil
+	return extractTar(resp.Body)
 }
 
 // extractTar 解压tar文件内容
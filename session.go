@@ -0,0 +1,112 @@
+package pritunl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"sync"
+)
+
+// 会话认证模式。新启动的pritunl镜像可能尚未开启api认证，此时可以使用管理员用户名密码登录/auth/session，
+// 之后的请求携带会话cookie和Csrf-Token头，会话过期(服务端返回401)时自动重新登录
+
+// SessionCredentials 会话认证使用的管理员账号信息
+type SessionCredentials struct {
+	Username string
+	Password string
+	// Otp 获取一次性验证码，管理员开启了两步验证时需要提供，每次登录时调用，可为空
+	Otp func() (string, error)
+}
+
+// sessionAuth 会话认证状态
+type sessionAuth struct {
+	creds SessionCredentials
+
+	mu        sync.Mutex
+	csrfToken string // 为空表示尚未登录或者会话已失效
+}
+
+// NewSessionClient 获取使用管理员用户名密码会话认证的pritunl客户端，首次请求时自动登录
+func NewSessionClient(creds SessionCredentials, host string, context context.Context) (*Client, error) {
+	if len(creds.Username) == 0 {
+		return nil, errors.New("username不能为空")
+	}
+	if len(creds.Password) == 0 {
+		return nil, errors.New("password不能为空")
+	}
+
+	// 先以占位的认证信息创建客户端，再切换为会话认证
+	client, err := NewClientWithProvider(StaticCredentials{}, host, context)
+	if err != nil {
+		return nil, err
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client.httpClient.Jar = jar
+	client.credentials.setSession(&sessionAuth{creds: creds})
+	return client, nil
+}
+
+// header 返回会话认证需要的请求头，尚未登录时先登录
+func (s *sessionAuth) header(c *Client) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.csrfToken) == 0 {
+		if err := s.login(c); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]string{
+		"Content-Type": applicationJSON,
+		"Csrf-Token":   s.csrfToken,
+	}, nil
+}
+
+// invalidate 将会话标记为失效，下次请求时重新登录
+func (s *sessionAuth) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.csrfToken = ""
+}
+
+// login 登录并获取csrf token，调用方需持有锁
+func (s *sessionAuth) login(c *Client) error {
+	body := map[string]string{
+		"username": s.creds.Username,
+		"password": s.creds.Password,
+	}
+	if s.creds.Otp != nil {
+		code, err := s.creds.Otp()
+		if err != nil {
+			return fmt.Errorf("get otp code failed, err: %w", err)
+		}
+		body["otp_code"] = code
+	}
+
+	loginOpts := RequestOpts{
+		JSONBody: body,
+	}
+	if _, err := c.doRequest(http.MethodPost, c.serverUrl(getAuthSessionUrl()), &loginOpts); err != nil {
+		return fmt.Errorf("session login failed, err: %w", err)
+	}
+
+	var state struct {
+		CsrfToken string `json:"csrf_token"`
+	}
+	stateOpts := RequestOpts{
+		JSONResponse: &state,
+	}
+	if _, err := c.doRequest(http.MethodGet, c.serverUrl(getAuthStateUrl()), &stateOpts); err != nil {
+		return fmt.Errorf("get session state failed, err: %w", err)
+	}
+	if len(state.CsrfToken) == 0 {
+		return errors.New("session state has no csrf token")
+	}
+	s.csrfToken = state.CsrfToken
+	return nil
+}
@@ -0,0 +1,190 @@
+package pritunl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSessionServer 管理员会话登录的替身，登录成功后下发会话cookie，/state返回与会话对应的csrf token
+type fakeSessionServer struct {
+	password string
+	otp      string // 不为空时登录需要校验otp_code
+
+	mu       sync.Mutex
+	logins   int
+	sessions map[string]string // 会话cookie -> csrf token
+	csrf     []string          // 业务请求携带的Csrf-Token
+}
+
+func newFakeSessionServer(password string) *fakeSessionServer {
+	return &fakeSessionServer{password: password, sessions: map[string]string{}}
+}
+
+// expire 使所有会话失效
+func (f *fakeSessionServer) expire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions = map[string]string{}
+}
+
+func (f *fakeSessionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/auth/session":
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body["username"] != DEFAULT_ADMIN_USER || body["password"] != f.password ||
+			(len(f.otp) > 0 && body["otp_code"] != f.otp) {
+			http.Error(w, "invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		f.logins++
+		session := fmt.Sprintf("session-%d", f.logins)
+		f.sessions[session] = fmt.Sprintf("csrf-%d", f.logins)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: session, Path: "/"})
+		writeJSON(w, map[string]interface{}{"authenticated": true})
+	case "/state":
+		csrf, ok := f.session(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"csrf_token": csrf})
+	default:
+		f.csrf = append(f.csrf, r.Header.Get("Csrf-Token"))
+		if csrf, ok := f.session(r); !ok || r.Header.Get("Csrf-Token") != csrf {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if len(r.Header.Get("Auth-Token")) > 0 {
+			http.Error(w, "unexpected api signature", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, Status{ServerVersion: "1.32.3805.95"})
+	}
+}
+
+// session 返回请求的会话对应的csrf token
+func (f *fakeSessionServer) session(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return "", false
+	}
+	csrf, ok := f.sessions[cookie.Value]
+	return csrf, ok
+}
+
+func newSessionTestClient(t *testing.T, fake *fakeSessionServer, creds SessionCredentials) *Client {
+	t.Helper()
+	srv := httptest.NewTLSServer(fake)
+	t.Cleanup(srv.Close)
+	client, err := NewSessionClient(creds, strings.TrimPrefix(srv.URL, "https://"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestSessionClientLogin(t *testing.T) {
+	fake := newFakeSessionServer("admin-password")
+	client := newSessionTestClient(t, fake, SessionCredentials{Username: DEFAULT_ADMIN_USER, Password: "admin-password"})
+
+	for i := 0; i < 2; i++ {
+		if _, err := GetStatus(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fake.logins != 1 {
+		t.Errorf("logins = %d, want the session reused", fake.logins)
+	}
+	if fmt.Sprint(fake.csrf) != "[csrf-1 csrf-1]" {
+		t.Errorf("csrf headers = %v, want the token from /state", fake.csrf)
+	}
+}
+
+func TestSessionClientReloginOnExpiry(t *testing.T) {
+	fake := newFakeSessionServer("admin-password")
+	client := newSessionTestClient(t, fake, SessionCredentials{Username: DEFAULT_ADMIN_USER, Password: "admin-password"})
+	if _, err := GetStatus(client); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.expire()
+	if _, err := GetStatus(client); err != nil {
+		t.Fatalf("GetStatus() after session expiry = %v, want transparent re-login", err)
+	}
+	if fake.logins != 2 {
+		t.Errorf("logins = %d, want exactly one re-login", fake.logins)
+	}
+	if fmt.Sprint(fake.csrf) != "[csrf-1 csrf-1 csrf-2]" {
+		t.Errorf("csrf headers = %v", fake.csrf)
+	}
+}
+
+func TestSessionClientLoginFailure(t *testing.T) {
+	fake := newFakeSessionServer("admin-password")
+	client := newSessionTestClient(t, fake, SessionCredentials{Username: DEFAULT_ADMIN_USER, Password: "wrong"})
+	if _, err := GetStatus(client); err == nil || !strings.Contains(err.Error(), "session login failed") {
+		t.Errorf("GetStatus() = %v, want login error", err)
+	}
+	if len(fake.csrf) != 0 {
+		t.Errorf("no request should be sent without a session: %v", fake.csrf)
+	}
+
+	// 开启两步验证的管理员需要提供otp
+	fake.otp = "123456"
+	client = newSessionTestClient(t, fake, SessionCredentials{
+		Username: DEFAULT_ADMIN_USER,
+		Password: "admin-password",
+		Otp:      func() (string, error) { return "123456", nil },
+	})
+	if _, err := GetStatus(client); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInitClientWithAdminPassword(t *testing.T) {
+	fake := newFakeSessionServer("admin-password")
+	srv := httptest.NewTLSServer(fake)
+	t.Cleanup(srv.Close)
+
+	client, err := newInitClient(context.Background(), InitOpts{
+		AdminIp:       strings.TrimPrefix(srv.URL, "https://"),
+		AdminPassword: "admin-password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.credentials.getSession() == nil {
+		t.Fatal("init client without api credentials should use session auth")
+	}
+	if _, err = GetStatus(client); err != nil {
+		t.Fatal(err)
+	}
+	if fake.logins != 1 {
+		t.Errorf("logins = %d, want 1", fake.logins)
+	}
+
+	client, err = newInitClient(context.Background(), InitOpts{
+		AdminIp:       strings.TrimPrefix(srv.URL, "https://"),
+		ApiToken:      "token",
+		ApiSecret:     "secret",
+		AdminPassword: "admin-password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.credentials.getSession() != nil {
+		t.Error("api credentials should take precedence over the admin password")
+	}
+}
@@ -0,0 +1,120 @@
+package pritunl
+
+// Settings pritunl的系统配置。所有字段都是指针类型，更新时只会提交非nil的字段，未设置的字段服务端保持不变，
+// 可以使用String、Bool、Int辅助函数构造字段值。读取时部分敏感字段(如密码)服务端不会返回
+type Settings struct {
+	// 管理员账号
+	Username *string `json:"username,omitempty"` // 当前管理员用户名
+	Password *string `json:"password,omitempty"` // 当前管理员密码，只写
+	Default  *bool   `json:"default,omitempty"`  // 是否仍在使用默认密码，只读
+
+	// 对外地址及网络
+	PublicAddress   *string `json:"public_address,omitempty"`    // 对外的ipv4地址，会写入用户连接配置文件
+	PublicAddress6  *string `json:"public_address6,omitempty"`   // 对外的ipv6地址
+	RoutedSubnet6   *string `json:"routed_subnet6,omitempty"`    // 路由到本机的ipv6子网
+	RoutedSubnet6Wg *string `json:"routed_subnet6_wg,omitempty"` // 路由到本机的wireguard ipv6子网
+	ReverseProxy    *bool   `json:"reverse_proxy,omitempty"`     // 是否部署在反向代理之后
+
+	// web服务
+	ServerPort *int    `json:"server_port,omitempty"` // web管理端口
+	AcmeDomain *string `json:"acme_domain,omitempty"` // 使用acme自动签发证书的域名
+	ServerCert *string `json:"server_cert,omitempty"` // web证书，pem格式
+	ServerKey  *string `json:"server_key,omitempty"`  // web证书私钥，pem格式
+
+	// 单点登录
+	Sso               *string   `json:"sso,omitempty"` // 单点登录提供方，如google、saml、okta、onelogin、radius、duo等，空表示关闭
+	SsoMatch          *[]string `json:"sso_match,omitempty"`
+	SsoToken          *string   `json:"sso_token,omitempty"`
+	SsoSecret         *string   `json:"sso_secret,omitempty"`
+	SsoHost           *string   `json:"sso_host,omitempty"`
+	SsoOrg            *string   `json:"sso_org,omitempty"` // 单点登录用户默认加入的组织id
+	SsoSamlUrl        *string   `json:"sso_saml_url,omitempty"`
+	SsoSamlIssuerUrl  *string   `json:"sso_saml_issuer_url,omitempty"`
+	SsoSamlCert       *string   `json:"sso_saml_cert,omitempty"`
+	SsoOktaAppId      *string   `json:"sso_okta_app_id,omitempty"`
+	SsoOktaToken      *string   `json:"sso_okta_token,omitempty"`
+	SsoOneloginAppId  *string   `json:"sso_onelogin_app_id,omitempty"`
+	SsoOneloginId     *string   `json:"sso_onelogin_id,omitempty"`
+	SsoOneloginSecret *string   `json:"sso_onelogin_secret,omitempty"`
+	SsoRadiusHost     *string   `json:"sso_radius_host,omitempty"`
+	SsoRadiusSecret   *string   `json:"sso_radius_secret,omitempty"`
+	SsoGoogleKey      *string   `json:"sso_google_key,omitempty"`
+	SsoGoogleEmail    *string   `json:"sso_google_email,omitempty"`
+	SsoDuoHost        *string   `json:"sso_duo_host,omitempty"`
+	SsoDuoToken       *string   `json:"sso_duo_token,omitempty"`
+	SsoDuoSecret      *string   `json:"sso_duo_secret,omitempty"`
+	SsoDuoMode        *string   `json:"sso_duo_mode,omitempty"`
+	SsoYubicoClient   *string   `json:"sso_yubico_client,omitempty"`
+	SsoYubicoSecret   *string   `json:"sso_yubico_secret,omitempty"`
+	SsoCache          *bool     `json:"sso_cache,omitempty"`
+	SsoClientCache    *bool     `json:"sso_client_cache,omitempty"`
+
+	// 邮件
+	EmailFrom     *string `json:"email_from,omitempty"`
+	EmailServer   *string `json:"email_server,omitempty"`
+	EmailUsername *string `json:"email_username,omitempty"`
+	EmailPassword *string `json:"email_password,omitempty"`
+
+	// 其他
+	Sandbox         *bool   `json:"sandbox,omitempty"`          // 沙箱模式
+	Theme           *string `json:"theme,omitempty"`            // 界面主题，light或者dark
+	Auditing        *string `json:"auditing,omitempty"`         // 审计模式，all或者空
+	Monitoring      *string `json:"monitoring,omitempty"`       // 监控方式，如influxdb
+	InfluxdbUri     *string `json:"influxdb_uri,omitempty"`     // influxdb地址
+	PinMode         *string `json:"pin_mode,omitempty"`         // 用户pin模式，optional、required、disabled
+	RestrictImport  *bool   `json:"restrict_import,omitempty"`  // 是否限制客户端只能通过uri导入配置
+	ClientReconnect *bool   `json:"client_reconnect,omitempty"` // 客户端是否自动重连
+}
+
+// SettingsUpdateResult 更新系统配置的结果
+type SettingsUpdateResult struct {
+	Settings         Settings // 服务端返回的更新后的配置
+	RestartWebServer bool     // 服务端返回的本次更新是否会导致pritunl web服务重启，重启期间api不可用
+}
+
+// settingsUpdateResponse 更新系统配置的响应体，在配置之外带有web服务是否重启的标记
+type settingsUpdateResponse struct {
+	Settings
+	RestartServer bool `json:"restart_server"`
+}
+
+// GetSettings 获取系统配置
+func GetSettings(c *Client) (*Settings, error) {
+	var settings Settings
+	opts := RequestOpts{
+		JSONResponse: &settings,
+	}
+	if _, err := c.Request("get", getServerSettingsPath(), &opts); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpdateSettings 更新系统配置，只会提交settings中非nil的字段。
+// 如果更新了web端口、证书、acme域名等配置，pritunl web服务会重启，可根据返回的RestartWebServer判断，该标记取自服务端响应
+func UpdateSettings(c *Client, settings Settings) (*SettingsUpdateResult, error) {
+	var resp settingsUpdateResponse
+	opts := RequestOpts{
+		JSONBody:     settings,
+		JSONResponse: &resp,
+	}
+	if _, err := c.Request("put", getServerSettingsPath(), &opts); err != nil {
+		return nil, err
+	}
+	return &SettingsUpdateResult{Settings: resp.Settings, RestartWebServer: resp.RestartServer}, nil
+}
+
+// String 返回字符串指针，用于构造Settings等部分更新的配置
+func String(v string) *string {
+	return &v
+}
+
+// Bool 返回bool指针，用于构造Settings等部分更新的配置
+func Bool(v bool) *bool {
+	return &v
+}
+
+// Int 返回int指针，用于构造Settings等部分更新的配置
+func Int(v int) *int {
+	return &v
+}
@@ -0,0 +1,151 @@
+package pritunl
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultAuthWindow 验签时允许的请求时间与本地时间的最大偏差
+const DefaultAuthWindow = 45 * time.Second
+
+var (
+	ErrAuthMissing       = errors.New("pritunl auth headers missing")
+	ErrAuthInvalidToken  = errors.New("pritunl auth token invalid")
+	ErrAuthTimestamp     = errors.New("pritunl auth timestamp out of window")
+	ErrAuthInvalidNonce  = errors.New("pritunl auth nonce invalid")
+	ErrAuthNonceReplayed = errors.New("pritunl auth nonce replayed")
+	ErrAuthSignature     = errors.New("pritunl auth signature invalid")
+)
+
+// Signer pritunl请求签名器，为http请求添加Auth-Token、Auth-Timestamp、Auth-Nonce、Auth-Signature头
+type Signer struct {
+	ApiToken  string
+	ApiSecret string
+	Now       func() time.Time // 签名时间，为空时使用time.Now
+	Nonce     func() string    // 随机串生成函数，为空时使用随机的32位字符串
+}
+
+// Sign 为请求签名，请求中已有的认证头会被替换，可用于代理中对转发的请求重新签名
+func (s Signer) Sign(req *http.Request) error {
+	now, nonce := time.Now, generateNonce
+	if s.Now != nil {
+		now = s.Now
+	}
+	if s.Nonce != nil {
+		nonce = s.Nonce
+	}
+
+	header, err := generateAuthHeader(req.URL.Path, req.Method, s.ApiToken, s.ApiSecret, now(), nonce())
+	if err != nil {
+		return err
+	}
+	for _, key := range []string{"Auth-Token", "Auth-Timestamp", "Auth-Nonce", "Auth-Signature"} {
+		req.Header.Set(key, header[key])
+	}
+	return nil
+}
+
+// Verifier pritunl请求验签器，校验token、时间窗口、随机串重放和签名。pritunl的签名不覆盖请求体，需要防篡改请求体时应使用https
+type Verifier struct {
+	lookup func(apiToken string) (apiSecret string, ok bool)
+	window time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	nonces map[string]time.Time // 时间窗口内出现过的随机串及其过期时间
+}
+
+// NewVerifier 创建验签器，lookup根据token查找对应的secret，window为允许的时间偏差，小于等于0时使用DefaultAuthWindow
+func NewVerifier(lookup func(apiToken string) (apiSecret string, ok bool), window time.Duration) *Verifier {
+	if window <= 0 {
+		window = DefaultAuthWindow
+	}
+	return &Verifier{
+		lookup: lookup,
+		window: window,
+		now:    time.Now,
+		nonces: map[string]time.Time{},
+	}
+}
+
+// SetClock 设置验签使用的时钟，主要用于单元测试
+func (v *Verifier) SetClock(now func() time.Time) {
+	v.now = now
+}
+
+// Verify 校验请求的签名，校验通过返回nil，否则返回ErrAuth开头的错误
+func (v *Verifier) Verify(req *http.Request) error {
+	token := req.Header.Get("Auth-Token")
+	timestamp := req.Header.Get("Auth-Timestamp")
+	nonce := req.Header.Get("Auth-Nonce")
+	signature := req.Header.Get("Auth-Signature")
+	if len(token) == 0 || len(timestamp) == 0 || len(nonce) == 0 || len(signature) == 0 {
+		return ErrAuthMissing
+	}
+
+	secret, ok := v.lookup(token)
+	if !ok {
+		return ErrAuthInvalidToken
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrAuthTimestamp
+	}
+	now := v.now()
+	if diff := now.Sub(time.Unix(unix, 0)); diff > v.window || diff < -v.window {
+		return ErrAuthTimestamp
+	}
+
+	if len(nonce) > 32 {
+		return ErrAuthInvalidNonce
+	}
+
+	expected, err := generateHMACBase64(secret, AuthString(token, timestamp, nonce, req.Method, req.URL.Path))
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrAuthSignature
+	}
+
+	// 签名校验通过后再记录随机串，避免伪造的请求占用随机串
+	if !v.useNonce(token+"&"+nonce, now) {
+		return ErrAuthNonceReplayed
+	}
+	return nil
+}
+
+// useNonce 记录随机串，随机串在时间窗口内已经出现过时返回false
+func (v *Verifier) useNonce(nonce string, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for key, expireAt := range v.nonces {
+		if now.After(expireAt) {
+			delete(v.nonces, key)
+		}
+	}
+	if _, ok := v.nonces[nonce]; ok {
+		return false
+	}
+	// 请求时间可以在窗口内前后偏移，所以随机串需要保留两个窗口的时间
+	v.nonces[nonce] = now.Add(2 * v.window)
+	return true
+}
+
+// Middleware 返回验签的http中间件，验签失败时返回401
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.Verify(r); err != nil {
+			http.Error(w, fmt.Sprintf("unauthorized: %s", err.Error()), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
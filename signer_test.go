@@ -0,0 +1,161 @@
+package pritunl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSigner(now time.Time, nonce string) Signer {
+	return Signer{
+		ApiToken:  "token",
+		ApiSecret: "secret",
+		Now:       func() time.Time { return now },
+		Nonce:     func() string { return nonce },
+	}
+}
+
+func newTestVerifier(now time.Time) *Verifier {
+	v := NewVerifier(func(token string) (string, bool) {
+		return "secret", token == "token"
+	}, 0)
+	v.SetClock(func() time.Time { return now })
+	return v
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	req := httptest.NewRequest(http.MethodPut, "/server/abc/start", strings.NewReader(`{"name":"office"}`))
+	if err := newTestSigner(now, "nonce-1").Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := newTestVerifier(now).Verify(req); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := map[string]struct {
+		tamper func(req *http.Request)
+		want   error
+	}{
+		"path": {
+			tamper: func(req *http.Request) { req.URL.Path = "/server/other/start" },
+			want:   ErrAuthSignature,
+		},
+		"method": {
+			tamper: func(req *http.Request) { req.Method = http.MethodDelete },
+			want:   ErrAuthSignature,
+		},
+		"signature": {
+			tamper: func(req *http.Request) { req.Header.Set("Auth-Signature", "AAAA"+req.Header.Get("Auth-Signature")[4:]) },
+			want:   ErrAuthSignature,
+		},
+		"timestamp": {
+			tamper: func(req *http.Request) {
+				req.Header.Set("Auth-Timestamp", strconv.FormatInt(now.Unix()-1, 10))
+			},
+			want: ErrAuthSignature,
+		},
+		"token": {
+			tamper: func(req *http.Request) { req.Header.Set("Auth-Token", "unknown") },
+			want:   ErrAuthInvalidToken,
+		},
+		"missing header": {
+			tamper: func(req *http.Request) { req.Header.Del("Auth-Nonce") },
+			want:   ErrAuthMissing,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/server/abc/start", nil)
+			if err := newTestSigner(now, "nonce-1").Sign(req); err != nil {
+				t.Fatal(err)
+			}
+			tt.tamper(req)
+			if err := newTestVerifier(now).Verify(req); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// pritunl的签名只覆盖token、时间戳、随机串、method和path，修改请求体不影响验签
+func TestVerifyTamperedBodyNotCovered(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	req := httptest.NewRequest(http.MethodPut, "/server/abc", strings.NewReader(`{"name":"office"}`))
+	if err := newTestSigner(now, "nonce-1").Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	tampered := httptest.NewRequest(http.MethodPut, "/server/abc", strings.NewReader(`{"name":"evil"}`))
+	tampered.Header = req.Header.Clone()
+	if err := newTestVerifier(now).Verify(tampered); err != nil {
+		t.Errorf("Verify() = %v, body is not part of the pritunl signature", err)
+	}
+}
+
+func TestVerifyStaleTimestamp(t *testing.T) {
+	signedAt := time.Unix(1700000000, 0)
+	tests := map[string]struct {
+		now  time.Time
+		want error
+	}{
+		"within window":   {now: signedAt.Add(DefaultAuthWindow - time.Second), want: nil},
+		"stale":           {now: signedAt.Add(DefaultAuthWindow + time.Second), want: ErrAuthTimestamp},
+		"from the future": {now: signedAt.Add(-DefaultAuthWindow - time.Second), want: ErrAuthTimestamp},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/server", nil)
+			if err := newTestSigner(signedAt, "nonce-1").Sign(req); err != nil {
+				t.Fatal(err)
+			}
+			if err := newTestVerifier(tt.now).Verify(req); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyNonceReplayed(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v := newTestVerifier(now)
+	req := httptest.NewRequest(http.MethodGet, "/server", nil)
+	if err := newTestSigner(now, "nonce-1").Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(req); !errors.Is(err, ErrAuthNonceReplayed) {
+		t.Errorf("replayed Verify() = %v, want %v", err, ErrAuthNonceReplayed)
+	}
+}
+
+func TestVerifierMiddleware(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	handler := newTestVerifier(now).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/server", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned request status = %d, want 401", rec.Code)
+	}
+
+	if err := newTestSigner(now, "nonce-2").Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("signed request status = %d, want 204", rec.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package pritunl
+
+// Ping 检查pritunl web服务是否存活，服务端返回200即为存活。
+// 请求不签名也不获取认证信息，认证信息缺失或过期不影响存活检查
+func Ping(c *Client) error {
+	_, err := c.doRequest("GET", c.serverUrl(getPingUrl()), &RequestOpts{})
+	return err
+}
+
+// Status pritunl的系统状态
+type Status struct {
+	OrgCount      int      `json:"org_count"`      // 组织数
+	UsersOnline   int      `json:"users_online"`   // 在线用户数
+	UserCount     int      `json:"user_count"`     // 用户总数
+	ServersOnline int      `json:"servers_online"` // 运行中的vpn server数
+	ServerCount   int      `json:"server_count"`   // vpn server总数
+	HostsOnline   int      `json:"hosts_online"`   // 在线主机数
+	HostCount     int      `json:"host_count"`     // 主机总数
+	ServerVersion string   `json:"server_version"` // pritunl版本，如1.32.3805.95
+	CurrentHost   string   `json:"current_host"`   // 处理本次请求的主机id
+	PublicIp      string   `json:"public_ip"`      // 当前主机的公网ip
+	LocalNetworks []string `json:"local_networks"` // 当前主机的本地网段
+	Notification  string   `json:"notification"`   // 服务端的通知消息，如新版本提示
+}
+
+// GetStatus 获取系统状态
+func GetStatus(c *Client) (*Status, error) {
+	var status Status
+	opts := RequestOpts{
+		JSONResponse: &status,
+	}
+	if _, err := c.Request("get", getStatusUrl(), &opts); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// HostUsage 主机在统计周期内的资源使用率，Cpu和Mem中每一项为[时间戳, 使用率百分比]
+type HostUsage struct {
+	Cpu [][2]float64 `json:"cpu"`
+	Mem [][2]float64 `json:"mem"`
+}
+
+// GetHostUsage 获取主机在指定统计周期的资源使用率，period取值见BandwidthPeriod开头的常量，
+// hostId可以使用Status.CurrentHost
+func GetHostUsage(c *Client, hostId, period string) (*HostUsage, error) {
+	var usage HostUsage
+	opts := RequestOpts{
+		JSONResponse: &usage,
+	}
+	if _, err := c.Request("get", getHostUsageUrl(hostId, period), &opts); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
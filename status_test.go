@@ -0,0 +1,40 @@
+package pritunl
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// failingCredentials 总是获取失败的认证信息
+type failingCredentials struct{}
+
+func (failingCredentials) Credentials() (Credentials, error) {
+	return Credentials{}, errors.New("credentials expired")
+}
+
+func TestPingWithoutCredentials(t *testing.T) {
+	var authHeader string
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != getPingUrl() {
+			http.NotFound(w, r)
+			return
+		}
+		authHeader = r.Header.Get("Auth-Token")
+		w.WriteHeader(http.StatusOK)
+	}))
+	if err := client.SetCredentialProvider(failingCredentials{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Ping(client); err != nil {
+		t.Fatalf("Ping() = %v, liveness should not depend on credentials", err)
+	}
+	if len(authHeader) > 0 {
+		t.Errorf("ping should be unsigned, got Auth-Token %q", authHeader)
+	}
+	if _, err := GetStatus(client); err == nil || !strings.Contains(err.Error(), "credentials expired") {
+		t.Errorf("GetStatus() = %v, want credential error", err)
+	}
+}
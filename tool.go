@@ -3,6 +3,8 @@ package pritunl
 import (
 	"fmt"
 	"math/rand"
+	"net"
+	"strings"
 )
 
 const (
@@ -22,3 +24,51 @@ func generateVpnServerName() string {
 
 	return fmt.Sprintf("server_%s", string(b))
 }
+
+// isIPv6Address 判断地址是否为ipv6地址，域名和ipv4地址返回false
+func isIPv6Address(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() == nil
+}
+
+// splitPublicAddress 将对外地址按ipv4地址或域名、ipv6地址分开，分别对应public_address和public_address6。
+// address本身是ipv6地址时作为ipv6地址，此时address6只能为空或者与其相同
+func splitPublicAddress(address, address6 string) (string, string, error) {
+	if !isValidPublicAddress(address) {
+		return "", "", fmt.Errorf("public addr is invalid")
+	}
+	if len(address6) > 0 && !isIPv6Address(address6) {
+		return "", "", fmt.Errorf("public addr6 is invalid")
+	}
+	if isIPv6Address(address) {
+		if len(address6) > 0 && address6 != address {
+			return "", "", fmt.Errorf("public addr is ipv6 address, conflicts with public addr6")
+		}
+		return "", address, nil
+	}
+	return address, address6, nil
+}
+
+// isValidPublicAddress 判断是否为合法的对外地址，可以是ipv4、ipv6地址或者域名
+func isValidPublicAddress(address string) bool {
+	if net.ParseIP(address) != nil {
+		return true
+	}
+	address = strings.TrimSuffix(address, ".")
+	if len(address) == 0 || len(address) > 253 {
+		return false
+	}
+	labels := strings.Split(address, ".")
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, ch := range label {
+			if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-') {
+				return false
+			}
+		}
+	}
+	// 顶级域名不能是纯数字，避免把格式错误的ip地址当成域名
+	return strings.Trim(labels[len(labels)-1], "0123456789") != ""
+}
@@ -0,0 +1,34 @@
+package pritunl
+
+import "testing"
+
+func TestSplitPublicAddress(t *testing.T) {
+	tests := []struct {
+		address  string
+		address6 string
+		want     string
+		want6    string
+		fails    bool
+	}{
+		{address: "1.2.3.4", want: "1.2.3.4"},
+		{address: "vpn.example.com", address6: "2001:db8::1", want: "vpn.example.com", want6: "2001:db8::1"},
+		{address: "2001:db8::1", want6: "2001:db8::1"},
+		{address: "2001:db8::1", address6: "2001:db8::1", want6: "2001:db8::1"},
+		{address: "2001:db8::1", address6: "2001:db8::2", fails: true},
+		{address: "1.2.3.4", address6: "5.6.7.8", fails: true},
+		{address: "1.2.3", fails: true},
+		{address: "", fails: true},
+	}
+	for _, tt := range tests {
+		got, got6, err := splitPublicAddress(tt.address, tt.address6)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("splitPublicAddress(%q, %q) should fail", tt.address, tt.address6)
+			}
+			continue
+		}
+		if err != nil || got != tt.want || got6 != tt.want6 {
+			t.Errorf("splitPublicAddress(%q, %q) = %q, %q, %v, want %q, %q", tt.address, tt.address6, got, got6, err, tt.want, tt.want6)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package pritunl
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // getServerSettingsPath 服务配置获取url
 func getServerSettingsPath() string {
@@ -22,11 +25,31 @@ func getCreateServerPath() string {
 	return "/server"
 }
 
+// getServerListPath 获取vpn server列表的url
+func getServerListPath() string {
+	return "/server"
+}
+
+// getServerUrl 获取单个vpn server的url
+func getServerUrl(serverId string) string {
+	return fmt.Sprintf("/server/%s", serverId)
+}
+
+// getServerBandwidthUrl 获取vpn server带宽统计的url
+func getServerBandwidthUrl(serverId, period string) string {
+	return fmt.Sprintf("/server/%s/bandwidth/%s", serverId, period)
+}
+
 // getOrganizationList 获取组织列表url
 func getOrganizationList() string {
 	return "/organization"
 }
 
+// getServerOrganizationsUrl 获取server已添加的组织列表url
+func getServerOrganizationsUrl(serverId string) string {
+	return fmt.Sprintf("/server/%s/organization", serverId)
+}
+
 // getAttachOrganizationUrl 获取添加组织url
 func getAttachOrganizationUrl(serverId, organizationId string) string {
 	return fmt.Sprintf("/server/%s/organization/%s", serverId, organizationId)
@@ -57,11 +80,31 @@ func getAddUserUrl(organizationId string) string {
 	return fmt.Sprintf("/user/%s", organizationId)
 }
 
+// getUserListUrl 获取组织下用户列表的url
+func getUserListUrl(organizationId string) string {
+	return fmt.Sprintf("/user/%s", organizationId)
+}
+
+// getUserUrl 获取用户详情的url
+func getUserUrl(organizationId, userId string) string {
+	return fmt.Sprintf("/user/%s/%s", organizationId, userId)
+}
+
 // getUpdateUserUrl 获取更新用户的url
 func getUpdateUserUrl(organizationId, userId string) string {
 	return fmt.Sprintf("/user/%s/%s", organizationId, userId)
 }
 
+// getDeleteUserUrl 获取删除用户的url
+func getDeleteUserUrl(organizationId, userId string) string {
+	return fmt.Sprintf("/user/%s/%s", organizationId, userId)
+}
+
+// getUserOtpSecretUrl 获取重置用户otp密钥的url
+func getUserOtpSecretUrl(organizationId, userId string) string {
+	return fmt.Sprintf("/user/%s/%s/otp_secret", organizationId, userId)
+}
+
 // getServerStartStopUrl 获取vpn server启动停止url
 func getServerStartStopUrl(serverId, operation string) string {
 	return fmt.Sprintf("/server/%s/operation/%s", serverId, operation)
@@ -71,3 +114,120 @@ func getServerStartStopUrl(serverId, operation string) string {
 func getExportConnectFileUrl(organizationId, userId string) string {
 	return fmt.Sprintf("/key/%s/%s.tar", organizationId, userId)
 }
+
+// getWireGuardKeyUrl 获取用户在server上进行wireguard密钥交换的url
+func getWireGuardKeyUrl(organizationId, userId, serverId string) string {
+	return fmt.Sprintf("/key/wg/%s/%s/%s", organizationId, userId, serverId)
+}
+
+// getLinkListUrl 获取link列表及创建link的url
+func getLinkListUrl() string {
+	return "/link"
+}
+
+// getLinkUrl 获取单个link的url
+func getLinkUrl(linkId string) string {
+	return fmt.Sprintf("/link/%s", linkId)
+}
+
+// getLinkLocationListUrl 获取link位置列表及创建位置的url
+func getLinkLocationListUrl(linkId string) string {
+	return fmt.Sprintf("/link/%s/location", linkId)
+}
+
+// getLinkLocationUrl 获取单个link位置的url
+func getLinkLocationUrl(linkId, locationId string) string {
+	return fmt.Sprintf("/link/%s/location/%s", linkId, locationId)
+}
+
+// getLinkRouteAddUrl 获取添加位置路由的url
+func getLinkRouteAddUrl(linkId, locationId string) string {
+	return fmt.Sprintf("/link/%s/location/%s/route", linkId, locationId)
+}
+
+// getLinkRouteUrl 获取单个位置路由的url
+func getLinkRouteUrl(linkId, locationId, routeId string) string {
+	return fmt.Sprintf("/link/%s/location/%s/route/%s", linkId, locationId, routeId)
+}
+
+// getLinkHostAddUrl 获取添加位置主机的url
+func getLinkHostAddUrl(linkId, locationId string) string {
+	return fmt.Sprintf("/link/%s/location/%s/host", linkId, locationId)
+}
+
+// getLinkHostUrl 获取单个位置主机的url
+func getLinkHostUrl(linkId, locationId, hostId string) string {
+	return fmt.Sprintf("/link/%s/location/%s/host/%s", linkId, locationId, hostId)
+}
+
+// getLinkHostUriUrl 获取位置主机连接uri的url
+func getLinkHostUriUrl(linkId, locationId, hostId string) string {
+	return fmt.Sprintf("/link/%s/location/%s/host/%s/uri", linkId, locationId, hostId)
+}
+
+// getLinkHostConfUrl 获取位置主机配置的url
+func getLinkHostConfUrl(linkId, locationId, hostId string) string {
+	return fmt.Sprintf("/link/%s/location/%s/host/%s/conf", linkId, locationId, hostId)
+}
+
+// getLinkExcludeAddUrl 获取添加位置排除规则的url
+func getLinkExcludeAddUrl(linkId, locationId string) string {
+	return fmt.Sprintf("/link/%s/location/%s/exclude", linkId, locationId)
+}
+
+// getLinkExcludeUrl 获取单个位置排除规则的url
+func getLinkExcludeUrl(linkId, locationId, excludeId string) string {
+	return fmt.Sprintf("/link/%s/location/%s/exclude/%s", linkId, locationId, excludeId)
+}
+
+// RouteTemplate 将请求路径中的id部分替换为{id}，得到路由模板，比如/server/674e68150d1fc18bf2c5ce4f/route
+// 会被转换为/server/{id}/route，用于日志、监控、链路追踪等需要低基数路由名的场景
+func RouteTemplate(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if isPathId(part) {
+			parts[i] = "{id}"
+		} else if name, ok := strings.CutSuffix(part, ".tar"); ok && isPathId(name) {
+			parts[i] = "{id}.tar"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// isPathId 判断路径中的一段是否为id，pritunl的id为24位十六进制字符串，路由id为网段的十六进制编码
+func isPathId(part string) bool {
+	if len(part) < 8 {
+		return false
+	}
+	for _, r := range part {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// getAuthSessionUrl 获取管理员会话登录的url
+func getAuthSessionUrl() string {
+	return "/auth/session"
+}
+
+// getAuthStateUrl 获取当前会话状态的url，响应中包含csrf token
+func getAuthStateUrl() string {
+	return "/state"
+}
+
+// getPingUrl 获取存活检查的url
+func getPingUrl() string {
+	return "/ping"
+}
+
+// getStatusUrl 获取系统状态的url
+func getStatusUrl() string {
+	return "/status"
+}
+
+// getHostUsageUrl 获取主机资源使用情况的url
+func getHostUsageUrl(hostId, period string) string {
+	return fmt.Sprintf("/host/%s/usage/%s", hostId, period)
+}
@@ -0,0 +1,139 @@
+package pritunl
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// 用户网络配置。端口转发将vpn server上的端口转发到用户，network_links声明用户身后的网段，
+// 其他用户可以经由该用户访问，dns_mapping为用户添加以用户名为域名的dns记录。
+// 静态地址：pritunl在用户首次关联server时从server网段中为其分配虚拟地址，之后重连保持不变，
+// 但api不支持为用户指定地址，因此这里只提供查询用户固定地址的GetUserStaticAddress，不提供设置
+
+// PortForward 端口转发规则
+type PortForward struct {
+	Protocol string `json:"protocol,omitempty"` // tcp或udp，为空时两种协议都转发
+	Port     string `json:"port"`               // 用户端口，单个端口如80，或者端口范围如8000-8100
+	Dport    string `json:"dport,omitempty"`    // server上对外的端口，为空时与Port相同
+}
+
+// Validate 校验端口转发规则
+func (p PortForward) Validate() error {
+	switch p.Protocol {
+	case "", "tcp", "udp":
+	default:
+		return fmt.Errorf("端口转发协议%s错误，只能为tcp或udp", p.Protocol)
+	}
+	portCount, err := parsePortRange(p.Port)
+	if err != nil {
+		return err
+	}
+	if len(p.Dport) > 0 {
+		dportCount, err := parsePortRange(p.Dport)
+		if err != nil {
+			return err
+		}
+		if dportCount != portCount {
+			return fmt.Errorf("端口范围%s与%s长度不一致", p.Port, p.Dport)
+		}
+	}
+	return nil
+}
+
+// parsePortRange 解析单个端口或端口范围，返回范围内的端口数
+func parsePortRange(ports string) (int, error) {
+	start, end, isRange := strings.Cut(ports, "-")
+	first, err := parsePort(start)
+	if err != nil {
+		return 0, err
+	}
+	if !isRange {
+		return 1, nil
+	}
+	last, err := parsePort(end)
+	if err != nil {
+		return 0, err
+	}
+	if last <= first {
+		return 0, fmt.Errorf("端口范围%s错误，结束端口必须大于起始端口", ports)
+	}
+	return last - first + 1, nil
+}
+
+// parsePort 解析端口号
+func parsePort(port string) (int, error) {
+	value, err := strconv.Atoi(port)
+	if err != nil || value < 1 || value > 65535 {
+		return 0, fmt.Errorf("端口%s错误，必须为1-65535之间的整数", port)
+	}
+	return value, nil
+}
+
+// SetUserPortForwarding 设置用户的端口转发规则，forwards为空时清空
+func SetUserPortForwarding(c *Client, organizationId, userId string, forwards []PortForward) (*UserDetail, error) {
+	if forwards == nil {
+		forwards = []PortForward{}
+	}
+	return UpdateUser(c, organizationId, userId, UserModifyOpts{PortForwarding: &forwards})
+}
+
+// SetUserNetworkLinks 设置用户身后的网段，links为空时清空
+func SetUserNetworkLinks(c *Client, organizationId, userId string, links []string) (*UserDetail, error) {
+	if links == nil {
+		links = []string{}
+	}
+	return UpdateUser(c, organizationId, userId, UserModifyOpts{NetworkLinks: &links})
+}
+
+// UserServer 用户在某个server上的地址和连接状态
+type UserServer struct {
+	Id             string `json:"id"`
+	Name           string `json:"name"`
+	Status         bool   `json:"status"`       // 是否已连接
+	RealAddress    string `json:"real_address"` // 客户端的公网地址，未连接时为空
+	VirtAddress    string `json:"virt_address"` // 分配给用户的虚拟地址，cidr格式
+	VirtAddress6   string `json:"virt_address6"`
+	ConnectedSince int64  `json:"connected_since"` // 连接开始的时间戳，未连接时为0
+}
+
+// UserStaticAddress 用户在server上的固定虚拟地址
+type UserStaticAddress struct {
+	ServerId string
+	Address  string // ipv4地址，不带掩码
+	Address6 string // ipv6地址，不带掩码，未启用ipv6时为空
+}
+
+// GetUserStaticAddress 查询pritunl为用户在server上分配的固定虚拟地址，用户所在组织未添加到server时返回错误
+func GetUserStaticAddress(c *Client, organizationId, userId, serverId string) (*UserStaticAddress, error) {
+	users, err := GetUserList(c, organizationId)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if user.Id != userId {
+			continue
+		}
+		for _, server := range user.Servers {
+			if server.Id != serverId || len(server.VirtAddress) == 0 {
+				continue
+			}
+			return &UserStaticAddress{
+				ServerId: serverId,
+				Address:  stripPrefixLength(server.VirtAddress),
+				Address6: stripPrefixLength(server.VirtAddress6),
+			}, nil
+		}
+		return nil, fmt.Errorf("用户%s在server %s上没有分配地址", userId, serverId)
+	}
+	return nil, fmt.Errorf("用户%s不存在", userId)
+}
+
+// stripPrefixLength 去掉cidr格式地址的掩码部分，不是cidr格式时原样返回
+func stripPrefixLength(address string) string {
+	if ip, _, err := net.ParseCIDR(address); err == nil {
+		return ip.String()
+	}
+	return address
+}
@@ -0,0 +1,55 @@
+package pritunl
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPortForwardValidate(t *testing.T) {
+	tests := []struct {
+		forward PortForward
+		valid   bool
+	}{
+		{PortForward{Port: "80"}, true},
+		{PortForward{Protocol: "tcp", Port: "8000-8100", Dport: "9000-9100"}, true},
+		{PortForward{Protocol: "icmp", Port: "80"}, false},
+		{PortForward{Port: "0"}, false},
+		{PortForward{Port: "70000"}, false},
+		{PortForward{Port: "8100-8000"}, false},
+		{PortForward{Port: "8000-8100", Dport: "9000"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.forward.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate(%+v) = %v, want valid %v", tt.forward, err, tt.valid)
+		}
+	}
+}
+
+func TestGetUserStaticAddress(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user/org1" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, []map[string]interface{}{
+			{"id": "u1", "name": "alice", "servers": []map[string]interface{}{
+				{"id": "s1", "name": "office", "status": false, "virt_address": "10.12.12.5/24", "virt_address6": "fd00:c0a8::5/64"},
+			}},
+			{"id": "u2", "name": "bob", "servers": []map[string]interface{}{}},
+		})
+	}))
+
+	address, err := GetUserStaticAddress(client, "org1", "u1", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address.Address != "10.12.12.5" || address.Address6 != "fd00:c0a8::5" {
+		t.Errorf("address = %+v", address)
+	}
+	if _, err = GetUserStaticAddress(client, "org1", "u2", "s1"); err == nil {
+		t.Error("user without address on the server should fail")
+	}
+	if _, err = GetUserStaticAddress(client, "org1", "u3", "s1"); err == nil {
+		t.Error("unknown user should fail")
+	}
+}
@@ -0,0 +1,268 @@
+package pritunl
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+)
+
+// 请求前的本地校验。server、路由、用户的配置在提交前先按pritunl的规则校验，
+// 所有不合法的字段一次性以ValidationErrors返回，避免拼写错误要等到服务端返回400才发现
+
+// ServerProtocol vpn server的协议
+type ServerProtocol string
+
+const (
+	ProtocolUdp ServerProtocol = "udp"
+	ProtocolTcp ServerProtocol = "tcp"
+)
+
+// ServerCipher vpn server的加密算法
+type ServerCipher string
+
+const (
+	CipherNone   ServerCipher = "none"
+	CipherAes128 ServerCipher = "aes128"
+	CipherAes192 ServerCipher = "aes192"
+	CipherAes256 ServerCipher = "aes256"
+)
+
+// ServerHash vpn server的消息摘要算法
+type ServerHash string
+
+const (
+	HashSha1   ServerHash = "sha1"
+	HashSha256 ServerHash = "sha256"
+	HashSha512 ServerHash = "sha512"
+)
+
+// NetworkMode vpn server的网络模式
+type NetworkMode string
+
+const (
+	NetworkModeTunnel NetworkMode = "tunnel" // 三层隧道
+	NetworkModeBridge NetworkMode = "bridge" // 二层桥接
+)
+
+// FieldError 单个字段的校验错误，Field为字段的json名称
+type FieldError struct {
+	Field   string
+	Value   interface{}
+	Message string
+}
+
+// Error 实现error接口
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors 一次校验中所有字段的错误，可以通过errors.As获取
+type ValidationErrors []FieldError
+
+// Error 实现error接口
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Error())
+	}
+	return "参数校验失败, " + strings.Join(messages, "; ")
+}
+
+// validator 收集字段错误
+type validator struct {
+	errs ValidationErrors
+}
+
+// add 记录一个字段错误
+func (v *validator) add(field string, value interface{}, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Field: field, Value: value, Message: fmt.Sprintf(format, args...)})
+}
+
+// required 校验字段不能为空
+func (v *validator) required(field, value string) {
+	if len(value) == 0 {
+		v.add(field, value, "不能为空")
+	}
+}
+
+// oneOf 校验字段取值，为空时不校验
+func oneOf[T ~string](v *validator, field string, value T, allowed ...T) {
+	if len(value) == 0 {
+		return
+	}
+	for _, item := range allowed {
+		if value == item {
+			return
+		}
+	}
+	v.add(field, value, "取值必须为%v之一", allowed)
+}
+
+// port 校验端口，为0时不校验
+func (v *validator) port(field string, value int) {
+	if value != 0 && (value < 1 || value > 65535) {
+		v.add(field, value, "必须为1-65535之间的整数")
+	}
+}
+
+// cidr 校验网段，ipv6为true时要求ipv6网段，否则要求ipv4网段，为空时不校验
+func (v *validator) cidr(field, value string, ipv6 bool) {
+	if len(value) == 0 {
+		return
+	}
+	ip, _, err := net.ParseCIDR(value)
+	if err != nil {
+		v.add(field, value, "必须为cidr格式")
+		return
+	}
+	if (ip.To4() == nil) != ipv6 {
+		if ipv6 {
+			v.add(field, value, "必须为ipv6网段")
+		} else {
+			v.add(field, value, "必须为ipv4网段")
+		}
+	}
+}
+
+// email 校验邮箱，为空时不校验
+func (v *validator) email(field, value string) {
+	if len(value) == 0 {
+		return
+	}
+	if _, err := mail.ParseAddress(value); err != nil {
+		v.add(field, value, "邮箱格式错误")
+	}
+}
+
+// err 没有错误时返回nil
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// validateServerNetwork 校验vpn server的网段，必须满足[10,172,192].[0-255,16-31,168].[0-255].0/[8-24]，且为网络地址
+func validateServerNetwork(v *validator, field, network string) {
+	if len(network) == 0 {
+		return
+	}
+	ip, ipNet, err := net.ParseCIDR(network)
+	if err != nil || ip.To4() == nil {
+		v.add(field, network, "必须为ipv4的cidr格式")
+		return
+	}
+	ip = ip.To4()
+	ones, _ := ipNet.Mask.Size()
+	private := ip[0] == 10 || ip[0] == 172 && ip[1] >= 16 && ip[1] <= 31 || ip[0] == 192 && ip[1] == 168
+	switch {
+	case !private:
+		v.add(field, network, "必须为10.0.0.0/8、172.16.0.0/12或192.168.0.0/16中的私有网段")
+	case ones < 8 || ones > 24:
+		v.add(field, network, "掩码长度必须在8-24之间")
+	case ip[3] != 0 || !ip.Equal(ipNet.IP):
+		v.add(field, network, "必须为网络地址，如%s", ipNet.String())
+	}
+}
+
+// Validate 校验server配置，CreateVpnServer在提交前会先填充默认值再校验
+func (s VpnServer) Validate() error {
+	v := &validator{}
+	validateServerNetwork(v, "network", s.Network)
+	v.port("port", s.Port)
+	oneOf(v, "protocol", s.Protocol, ProtocolUdp, ProtocolTcp)
+	oneOf(v, "cipher", s.Cipher, CipherNone, CipherAes128, CipherAes192, CipherAes256)
+	oneOf(v, "hash", s.Hash, HashSha1, HashSha256, HashSha512)
+	oneOf(v, "network_mode", s.NetworkMode, NetworkModeTunnel, NetworkModeBridge)
+	v.port("port_wg", s.PortWg)
+	validateServerNetwork(v, "network_wg", s.NetworkWg)
+	v.cidr("network6", s.Network6, true)
+	if s.Wg && s.Port != 0 && s.Port == s.PortWg && s.Protocol != ProtocolTcp {
+		v.add("port_wg", s.PortWg, "不能与openvpn的udp端口相同")
+	}
+	return v.err()
+}
+
+// Validate 校验server更新配置
+func (o ServerUpdateOpts) Validate() error {
+	v := &validator{}
+	if o.PortWg != nil {
+		v.port("port_wg", *o.PortWg)
+	}
+	if o.NetworkWg != nil {
+		validateServerNetwork(v, "network_wg", *o.NetworkWg)
+	}
+	if o.Network6 != nil {
+		v.cidr("network6", *o.Network6, true)
+	}
+	return v.err()
+}
+
+// Validate 校验路由添加配置
+func (o RouteAddOpts) Validate() error {
+	v := &validator{}
+	v.required("server", o.Server)
+	v.required("network", o.Network)
+	if len(o.Network) > 0 {
+		if _, _, err := net.ParseCIDR(o.Network); err != nil {
+			v.add("network", o.Network, "必须为cidr格式")
+		}
+	}
+	return v.err()
+}
+
+// Validate 校验路由更新配置
+func (o RouteUpdateOpts) Validate() error {
+	v := &validator{}
+	v.required("id", o.Id)
+	v.required("server", o.Server)
+	if len(o.Network) > 0 {
+		if _, _, err := net.ParseCIDR(o.Network); err != nil {
+			v.add("network", o.Network, "必须为cidr格式")
+		}
+	}
+	return v.err()
+}
+
+// Validate 校验用户添加配置
+func (o UserAddOpts) Validate() error {
+	v := &validator{}
+	v.required("name", o.Name)
+	v.required("organizationId", o.OrganizationId)
+	v.email("email", o.Email)
+	return v.err()
+}
+
+// Validate 校验用户更新配置
+func (o UserModifyOpts) Validate() error {
+	v := &validator{}
+	if o.Name != nil {
+		v.required("name", *o.Name)
+	}
+	if o.Email != nil {
+		v.email("email", *o.Email)
+	}
+	if o.PortForwarding != nil {
+		for i, forward := range *o.PortForwarding {
+			if err := forward.Validate(); err != nil {
+				v.add(fmt.Sprintf("port_forwarding[%d]", i), forward, "%s", err.Error())
+			}
+		}
+	}
+	if o.NetworkLinks != nil {
+		for i, link := range *o.NetworkLinks {
+			if _, _, err := net.ParseCIDR(link); err != nil {
+				v.add(fmt.Sprintf("network_links[%d]", i), link, "必须为cidr格式")
+			}
+		}
+	}
+	if o.DnsServers != nil {
+		for i, server := range *o.DnsServers {
+			if net.ParseIP(server) == nil {
+				v.add(fmt.Sprintf("dns_servers[%d]", i), server, "必须为ip地址")
+			}
+		}
+	}
+	return v.err()
+}
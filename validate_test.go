@@ -0,0 +1,188 @@
+package pritunl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fieldErrors 从err中取出ValidationErrors，返回出错的字段
+func fieldErrors(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error %v is not ValidationErrors", err)
+	}
+	var fields []string
+	for _, fieldErr := range errs {
+		fields = append(fields, fieldErr.Field)
+	}
+	return fields
+}
+
+func TestValidateServerNetwork(t *testing.T) {
+	tests := []struct {
+		network string
+		message string // 为空表示校验通过
+	}{
+		{"", ""},
+		{"10.0.0.0/8", ""},
+		{"10.20.30.0/24", ""},
+		{"172.16.0.0/12", ""},
+		{"172.31.255.0/24", ""},
+		{"192.168.100.0/24", ""},
+		{"8.8.8.0/24", "私有网段"},
+		{"172.15.0.0/16", "私有网段"},
+		{"172.32.0.0/16", "私有网段"},
+		{"192.169.0.0/16", "私有网段"},
+		{"10.0.0.0/7", "掩码长度"},
+		{"10.0.0.0/25", "掩码长度"},
+		{"192.168.1.0/30", "掩码长度"},
+		{"10.0.0.1/24", "网络地址，如10.0.0.0/24"},
+		{"10.0.1.0/16", "网络地址，如10.0.0.0/16"},
+		{"192.168.1.128/24", "网络地址"},
+		{"10.0.0.0", "cidr格式"},
+		{"fd00::/64", "cidr格式"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			v := &validator{}
+			validateServerNetwork(v, "network", tt.network)
+			if len(tt.message) == 0 {
+				if err := v.err(); err != nil {
+					t.Errorf("validateServerNetwork(%q) = %v, want valid", tt.network, err)
+				}
+				return
+			}
+			if len(v.errs) != 1 || v.errs[0].Field != "network" || v.errs[0].Value != tt.network ||
+				!strings.Contains(v.errs[0].Message, tt.message) {
+				t.Errorf("validateServerNetwork(%q) = %v, want one error containing %q", tt.network, v.errs, tt.message)
+			}
+		})
+	}
+}
+
+func TestVpnServerValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		server VpnServer
+		fields []string // 期望出错的字段，为空表示校验通过
+	}{
+		{
+			name:   "defaults",
+			server: VpnServer{Network: "10.1.0.0/24", Port: 1194, Protocol: ProtocolUdp, Cipher: CipherAes128, Hash: HashSha1},
+		},
+		{
+			name:   "wireguard on another port",
+			server: VpnServer{Network: "10.1.0.0/24", Port: 1194, Wg: true, PortWg: 1195, NetworkWg: "10.2.0.0/24"},
+		},
+		{
+			name:   "wireguard and openvpn udp on the same port",
+			server: VpnServer{Network: "10.1.0.0/24", Port: 1194, Protocol: ProtocolUdp, Wg: true, PortWg: 1194},
+			fields: []string{"port_wg"},
+		},
+		{
+			name:   "default protocol is udp",
+			server: VpnServer{Port: 1194, Wg: true, PortWg: 1194},
+			fields: []string{"port_wg"},
+		},
+		{
+			name:   "openvpn tcp may share the wireguard port",
+			server: VpnServer{Port: 1194, Protocol: ProtocolTcp, Wg: true, PortWg: 1194},
+		},
+		{
+			name:   "same port without wireguard",
+			server: VpnServer{Port: 1194, PortWg: 1194},
+		},
+		{
+			name:   "wireguard network must be a network address",
+			server: VpnServer{Wg: true, PortWg: 1195, NetworkWg: "10.2.0.1/24"},
+			fields: []string{"network_wg"},
+		},
+		{
+			name: "collects every invalid field",
+			server: VpnServer{
+				Network:     "10.0.0.1/24",
+				Port:        70000,
+				Protocol:    "icmp",
+				Cipher:      "des",
+				Hash:        "md5",
+				NetworkMode: "nat",
+				PortWg:      -1,
+				NetworkWg:   "8.8.8.0/24",
+				Network6:    "10.0.0.0/24",
+			},
+			fields: []string{"network", "port", "protocol", "cipher", "hash", "network_mode", "port_wg", "network_wg", "network6"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := fieldErrors(t, tt.server.Validate())
+			if fmt.Sprint(fields) != fmt.Sprint(tt.fields) {
+				t.Errorf("Validate() fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestUserModifyOptsValidate(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name   string
+		opts   UserModifyOpts
+		fields []string
+	}{
+		{name: "nothing to update", opts: UserModifyOpts{}},
+		{
+			name: "valid update",
+			opts: UserModifyOpts{
+				Name:           str("alice"),
+				Email:          str("alice@example.com"),
+				PortForwarding: &[]PortForward{{Protocol: "tcp", Port: "80", Dport: "8080"}},
+				NetworkLinks:   &[]string{"10.10.0.0/24"},
+				DnsServers:     &[]string{"8.8.8.8", "2001:4860:4860::8888"},
+			},
+		},
+		{name: "clear email", opts: UserModifyOpts{Email: str("")}},
+		{name: "empty name", opts: UserModifyOpts{Name: str("")}, fields: []string{"name"}},
+		{
+			name: "collects every invalid field",
+			opts: UserModifyOpts{
+				Email:          str("not-an-email"),
+				PortForwarding: &[]PortForward{{Port: "80"}, {Protocol: "icmp", Port: "80"}},
+				NetworkLinks:   &[]string{"10.10.0.0/24", "10.10.0.0"},
+				DnsServers:     &[]string{"dns.example.com"},
+			},
+			fields: []string{"email", "port_forwarding[1]", "network_links[1]", "dns_servers[0]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := fieldErrors(t, tt.opts.Validate())
+			if fmt.Sprint(fields) != fmt.Sprint(tt.fields) {
+				t.Errorf("Validate() fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidationErrorsUnwrap(t *testing.T) {
+	err := fmt.Errorf("create server failed, err: %w", VpnServer{Network: "8.8.8.0/24", Port: 70000}.Validate())
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("errors.As(%v) failed", err)
+	}
+	if len(errs) != 2 || errs[0].Field != "network" || errs[1].Field != "port" || errs[1].Value != 70000 {
+		t.Errorf("errors = %+v, want network and port", errs)
+	}
+	for _, s := range []string{"network: ", "port: ", "; "} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("error message %q should contain %q", err.Error(), s)
+		}
+	}
+}
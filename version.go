@@ -0,0 +1,293 @@
+package pritunl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 版本兼容。不同版本的pritunl支持的功能、字段名和接口地址不同，客户端在首次调用依赖版本的请求时通过GetStatus探测服务端版本并缓存，
+// 再按兼容表判断功能是否可用、改写字段名和接口地址，功能不可用时返回ErrUnsupportedByServer，而不是等服务端返回难以理解的404或400。
+// 探测失败(如api账号无权访问/status)时视为版本未知，按当前版本处理，不会因此拒绝请求
+
+// ErrUnsupportedByServer 服务端版本不支持请求的功能
+var ErrUnsupportedByServer = errors.New("pritunl服务端版本不支持该功能")
+
+// Version pritunl版本号，格式为主版本.次版本.构建号.补丁号，如1.32.3805.95
+type Version struct {
+	Major int
+	Minor int
+	Build int
+	Patch int
+}
+
+// ParseVersion 解析版本号，允许只有前两段或者前三段，缺少的部分视为0
+func ParseVersion(version string) (Version, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	if len(parts) < 2 || len(parts) > 4 {
+		return Version{}, fmt.Errorf("版本号%s格式错误", version)
+	}
+	numbers := [4]int{}
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return Version{}, fmt.Errorf("版本号%s格式错误", version)
+		}
+		numbers[i] = number
+	}
+	return Version{Major: numbers[0], Minor: numbers[1], Build: numbers[2], Patch: numbers[3]}, nil
+}
+
+// String 返回版本号字符串
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Build, v.Patch)
+}
+
+// Compare 比较版本，v小于、等于、大于other时分别返回-1、0、1
+func (v Version) Compare(other Version) int {
+	a := [4]int{v.Major, v.Minor, v.Build, v.Patch}
+	b := [4]int{other.Major, other.Minor, other.Build, other.Patch}
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// AtLeast 判断v是否不低于other
+func (v Version) AtLeast(other Version) bool {
+	return v.Compare(other) >= 0
+}
+
+// Feature 依赖服务端版本的功能
+type Feature string
+
+const (
+	FeatureWireGuard Feature = "wireguard" // server的wireguard模式
+)
+
+// featureMinVersions 兼容表，记录每个功能要求的最低服务端版本，不在表中的功能所有版本都支持。
+// 新增条目时需要有pritunl更新日志作为依据
+var featureMinVersions = map[Feature]Version{
+	FeatureWireGuard: {Major: 1, Minor: 30}, // wireguard从1.30开始支持
+}
+
+// Supports 判断version是否支持功能
+func (v Version) Supports(feature Feature) bool {
+	minVersion, ok := featureMinVersions[feature]
+	return !ok || v.AtLeast(minVersion)
+}
+
+// Resource 兼容表中字段更名涉及的资源
+type Resource string
+
+const (
+	ResourceUser Resource = "user" // 用户
+)
+
+// fieldRename 字段更名，服务端版本低于Since时字段名为OldName
+type fieldRename struct {
+	Resource Resource
+	Name     string
+	OldName  string
+	Since    Version
+}
+
+// fieldRenames 兼容表，记录字段更名，请求体和响应体中的字段按服务端版本改写。
+// 目前支持的版本之间没有确认过的字段更名，新增条目时需要有pritunl更新日志作为依据
+var fieldRenames []fieldRename
+
+// Endpoint 兼容表中地址有变化的接口
+type Endpoint string
+
+const (
+	EndpointServerOperation Endpoint = "server_operation" // vpn server启动、停止、重启
+)
+
+// endpointChange 接口地址变化，服务端版本低于Since时使用Format生成地址
+type endpointChange struct {
+	Endpoint Endpoint
+	Format   string
+	Since    Version
+}
+
+// endpointChanges 兼容表，记录接口地址变化，不在表中的接口所有版本都使用url.go中的地址。
+// 目前支持的版本之间没有确认过的地址变化，新增条目时需要有pritunl更新日志作为依据
+var endpointChanges []endpointChange
+
+// versionRetryInterval 版本探测失败后，兼容判断重新探测的间隔
+const versionRetryInterval = time.Minute
+
+// versionHolder 服务端版本缓存，通过WithContext得到的副本共享同一份缓存。只缓存探测成功的版本
+type versionHolder struct {
+	mu       sync.Mutex
+	version  *Version
+	failedAt time.Time // 最近一次探测失败的时间
+}
+
+// ServerVersion 返回服务端版本，首次调用时通过GetStatus探测，成功后缓存，探测失败时下次调用会重新探测
+func (c *Client) ServerVersion() (Version, error) {
+	c.version.mu.Lock()
+	defer c.version.mu.Unlock()
+	if c.version.version != nil {
+		return *c.version.version, nil
+	}
+
+	version, err := c.detectVersion()
+	if err != nil {
+		c.version.failedAt = time.Now()
+		return Version{}, err
+	}
+	c.version.version = &version
+	return version, nil
+}
+
+// SetServerVersion 指定服务端版本，之后不再探测。用于已知版本或者api账号无权访问/status的场景
+func (c *Client) SetServerVersion(version Version) {
+	c.version.mu.Lock()
+	defer c.version.mu.Unlock()
+	c.version.version = &version
+}
+
+// detectVersion 通过GetStatus探测服务端版本
+func (c *Client) detectVersion() (Version, error) {
+	status, err := GetStatus(c)
+	if err != nil {
+		return Version{}, fmt.Errorf("detect server version failed, err: %w", err)
+	}
+	return ParseVersion(status.ServerVersion)
+}
+
+// knownVersion 返回兼容判断使用的服务端版本，版本未知时返回nil。探测成功后不再探测，
+// 探测失败时在versionRetryInterval内按版本未知处理，之后再次探测，避免一次网络错误导致版本永远未知
+func (c *Client) knownVersion() *Version {
+	c.version.mu.Lock()
+	defer c.version.mu.Unlock()
+	if c.version.version == nil && time.Since(c.version.failedAt) >= versionRetryInterval {
+		if version, err := c.detectVersion(); err == nil {
+			c.version.version = &version
+		} else {
+			c.version.failedAt = time.Now()
+		}
+	}
+	return c.version.version
+}
+
+// requireFeature 判断服务端是否支持功能，不支持时返回包装了ErrUnsupportedByServer的错误，版本未知时视为支持
+func (c *Client) requireFeature(feature Feature) error {
+	version := c.knownVersion()
+	if version == nil || version.Supports(feature) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s requires pritunl %s or later, server version is %s",
+		ErrUnsupportedByServer, feature, featureMinVersions[feature], version)
+}
+
+// endpointPath 返回接口在服务端版本下的地址，path为当前版本的地址，args为生成旧地址的参数
+func (c *Client) endpointPath(endpoint Endpoint, path string, args ...interface{}) string {
+	for _, change := range endpointChanges {
+		if change.Endpoint != endpoint {
+			continue
+		}
+		if version := c.knownVersion(); version != nil && !version.AtLeast(change.Since) {
+			return fmt.Sprintf(change.Format, args...)
+		}
+	}
+	return path
+}
+
+// renamesFor 返回服务端版本下需要改写的字段，key为当前字段名，value为旧字段名
+func (c *Client) renamesFor(resource Resource) map[string]string {
+	renames := map[string]string{}
+	for _, rename := range fieldRenames {
+		if rename.Resource != resource {
+			continue
+		}
+		if version := c.knownVersion(); version != nil && !version.AtLeast(rename.Since) {
+			renames[rename.Name] = rename.OldName
+		}
+	}
+	return renames
+}
+
+// resourceRequest 按兼容表改写请求体和响应体中的字段名后执行请求，服务端版本不需要改写时等同于Request
+func (c *Client) resourceRequest(method, path string, resource Resource, options *RequestOpts) (*http.Response, error) {
+	if options == nil {
+		options = &RequestOpts{}
+	}
+	renames := c.renamesFor(resource)
+	if len(renames) == 0 {
+		return c.Request(method, path, options)
+	}
+
+	opts := *options
+	if opts.JSONBody != nil {
+		body, err := renameJSONFields(opts.JSONBody, renames)
+		if err != nil {
+			return nil, err
+		}
+		opts.JSONBody = body
+	}
+	var raw json.RawMessage
+	if opts.JSONResponse != nil {
+		opts.JSONResponse = &raw
+	}
+	resp, err := c.Request(method, path, &opts)
+	if err != nil || options.JSONResponse == nil {
+		return resp, err
+	}
+
+	reverse := make(map[string]string, len(renames))
+	for name, oldName := range renames {
+		reverse[oldName] = name
+	}
+	renamed, err := renameJSONFields(raw, reverse)
+	if err != nil {
+		return resp, err
+	}
+	return resp, json.Unmarshal(renamed.(json.RawMessage), options.JSONResponse)
+}
+
+// renameJSONFields 改写json对象或者对象数组中的字段名，返回改写后的json
+func renameJSONFields(v interface{}, renames map[string]string) (interface{}, error) {
+	data, ok := v.(json.RawMessage)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	rename := func(object map[string]interface{}) {
+		for name, newName := range renames {
+			if value, ok := object[name]; ok {
+				delete(object, name)
+				object[newName] = value
+			}
+		}
+	}
+	switch decoded := decoded.(type) {
+	case map[string]interface{}:
+		rename(decoded)
+	case []interface{}:
+		for _, item := range decoded {
+			if object, ok := item.(map[string]interface{}); ok {
+				rename(object)
+			}
+		}
+	}
+	renamed, err := json.Marshal(decoded)
+	return json.RawMessage(renamed), err
+}
@@ -0,0 +1,175 @@
+package pritunl
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVersionServer 按指定版本响应/status的替身，记录收到的请求
+type fakeVersionServer struct {
+	mu       sync.Mutex
+	version  string // 为空时/status返回403
+	statuses int
+	paths    []string
+	bodies   []map[string]interface{}
+}
+
+func (f *fakeVersionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/status" {
+		f.statuses++
+		if len(f.version) == 0 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		writeJSON(w, Status{ServerVersion: f.version})
+		return
+	}
+	f.paths = append(f.paths, r.Method+" "+r.URL.Path)
+	var body map[string]interface{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	f.bodies = append(f.bodies, body)
+	switch r.URL.Path {
+	case "/user/org1":
+		writeJSON(w, []map[string]interface{}{{"id": "u1", "name": "alice", "legacy_yubico_id": "ccccccjlkhrt"}})
+	case "/user/org1/u1":
+		response := map[string]interface{}{"id": "u1", "name": "alice"}
+		for key, value := range body {
+			response[key] = value
+		}
+		writeJSON(w, response)
+	default:
+		writeJSON(w, map[string]interface{}{"id": "s1", "status": ServerStatusOnline})
+	}
+}
+
+// setCompatTables 在测试期间替换字段更名和接口地址变化的兼容表，测试结束后恢复
+func setCompatTables(t *testing.T, renames []fieldRename, changes []endpointChange) {
+	t.Helper()
+	oldRenames, oldChanges := fieldRenames, endpointChanges
+	fieldRenames, endpointChanges = renames, changes
+	t.Cleanup(func() {
+		fieldRenames, endpointChanges = oldRenames, oldChanges
+	})
+}
+
+func TestRequireFeatureUnknownVersion(t *testing.T) {
+	fake := &fakeVersionServer{}
+	client := newTestClient(t, fake)
+
+	for i := 0; i < 2; i++ {
+		if err := client.requireFeature(FeatureWireGuard); err != nil {
+			t.Fatalf("requireFeature() = %v, failed detection should allow the request", err)
+		}
+	}
+	if fake.statuses != 1 {
+		t.Errorf("status requests = %d, want no retry within the retry interval", fake.statuses)
+	}
+	if _, err := client.ServerVersion(); err == nil {
+		t.Error("ServerVersion() should report the detection error")
+	}
+
+	// 超过重试间隔后重新探测，探测成功的版本被缓存
+	fake.mu.Lock()
+	fake.version = "1.29.2664.67"
+	fake.mu.Unlock()
+	client.version.mu.Lock()
+	client.version.failedAt = time.Now().Add(-versionRetryInterval)
+	client.version.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		if err := client.requireFeature(FeatureWireGuard); !errors.Is(err, ErrUnsupportedByServer) {
+			t.Fatalf("requireFeature() = %v, want ErrUnsupportedByServer after re-detection", err)
+		}
+	}
+	if fake.statuses != 3 {
+		t.Errorf("status requests = %d, want one re-detection and the result cached", fake.statuses)
+	}
+}
+
+func TestRequireFeatureOldVersion(t *testing.T) {
+	fake := &fakeVersionServer{version: "1.29.2664.67"}
+	client := newTestClient(t, fake)
+
+	_, err := EnableServerWireGuard(client, "s1", 51820, "10.2.0.0/24")
+	if !errors.Is(err, ErrUnsupportedByServer) {
+		t.Fatalf("EnableServerWireGuard() = %v, want ErrUnsupportedByServer", err)
+	}
+	if err = client.requireFeature(Feature("not_in_table")); err != nil {
+		t.Errorf("feature not in the table should be supported, err: %v", err)
+	}
+	if fake.statuses != 1 || len(fake.paths) != 0 {
+		t.Errorf("statuses = %d, paths = %v", fake.statuses, fake.paths)
+	}
+}
+
+func TestFieldRenames(t *testing.T) {
+	setCompatTables(t, []fieldRename{
+		{Resource: ResourceUser, Name: "yubico_id", OldName: "legacy_yubico_id", Since: Version{Major: 1, Minor: 30}},
+	}, nil)
+	tests := []struct {
+		version string
+		field   string
+	}{
+		{"1.29.2664.67", "legacy_yubico_id"},
+		{"1.32.3805.95", "yubico_id"},
+		{"", "yubico_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			fake := &fakeVersionServer{version: tt.version}
+			client := newTestClient(t, fake)
+
+			user, err := UpdateUser(client, "org1", "u1", UserModifyOpts{YubicoId: String("ccccccjlkhrt")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := fake.bodies[0][tt.field]; !ok || len(fake.bodies[0]) != 1 {
+				t.Errorf("request body = %v, want field %s", fake.bodies[0], tt.field)
+			}
+			if user.YubicoId != "ccccccjlkhrt" {
+				t.Errorf("response field not decoded: %+v", user)
+			}
+		})
+	}
+
+	fake := &fakeVersionServer{version: "1.29.2664.67"}
+	users, err := GetUserList(newTestClient(t, fake), "org1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].YubicoId != "ccccccjlkhrt" {
+		t.Errorf("old field in list response not decoded: %+v", users)
+	}
+}
+
+func TestEndpointChanges(t *testing.T) {
+	setCompatTables(t, nil, []endpointChange{
+		{Endpoint: EndpointServerOperation, Format: "/server/%s/legacy/%s", Since: Version{Major: 1, Minor: 30}},
+	})
+	tests := []struct {
+		version string
+		path    string
+	}{
+		{"1.29.2664.67", "PUT /server/s1/legacy/start"},
+		{"1.32.3805.95", "PUT /server/s1/operation/start"},
+		{"", "PUT /server/s1/operation/start"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			fake := &fakeVersionServer{version: tt.version}
+			if _, err := StartStopServer(newTestClient(t, fake), "s1", true); err != nil {
+				t.Fatal(err)
+			}
+			if len(fake.paths) != 1 || fake.paths[0] != tt.path {
+				t.Errorf("paths = %v, want %s", fake.paths, tt.path)
+			}
+		})
+	}
+}
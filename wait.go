@@ -0,0 +1,176 @@
+package pritunl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	ServerStatusOnline  = "online"  // server运行中
+	ServerStatusOffline = "offline" // server已停止
+)
+
+const (
+	DefaultWaitInterval = 2 * time.Second // 默认的轮询间隔
+	DefaultWaitTimeout  = 2 * time.Minute // 默认的等待超时时间
+)
+
+// ErrServerNotFound 等待过程中server不存在或者已被删除
+var ErrServerNotFound = errors.New("vpn server不存在")
+
+// WaitOpts 等待选项
+type WaitOpts struct {
+	Interval      time.Duration // 轮询间隔，不给的话默认为DefaultWaitInterval
+	Timeout       time.Duration // 超时时间，不给的话默认为DefaultWaitTimeout，ctx先到期时以ctx为准
+	FailureStates []string      // 出现这些状态时立即返回错误，不再继续等待
+}
+
+// withDefaults 填充默认值
+func (o *WaitOpts) withDefaults() WaitOpts {
+	opts := WaitOpts{}
+	if o != nil {
+		opts = *o
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultWaitInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWaitTimeout
+	}
+	return opts
+}
+
+// RestartServer 重启vpn server，重启期间已连接的用户会断开
+func RestartServer(c *Client, serverId string) (*VpnServer, error) {
+	var server VpnServer
+	opts := RequestOpts{
+		JSONResponse: &server,
+	}
+	path := c.endpointPath(EndpointServerOperation, getServerStartStopUrl(serverId, "restart"), serverId, "restart")
+	if _, err := c.Request("put", path, &opts); err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+// WaitForServerState 轮询直到server进入state状态，返回最后一次获取到的server。
+// server不存在时返回ErrServerNotFound，进入opts.FailureStates中的状态或者超时时返回错误，获取server的网络错误会继续重试
+func WaitForServerState(ctx context.Context, c *Client, serverId, state string, opts *WaitOpts) (*VpnServer, error) {
+	waitOpts := opts.withDefaults()
+	ctx, cancel := context.WithTimeout(ctx, waitOpts.Timeout)
+	defer cancel()
+	client := c.WithContext(ctx)
+
+	var server VpnServer
+	var lastErr error
+	err := poll(ctx, waitOpts.Interval, func() (bool, error) {
+		server = VpnServer{}
+		resp, err := client.Request("get", getServerUrl(serverId), &RequestOpts{JSONResponse: &server})
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return false, ErrServerNotFound
+			}
+			lastErr = err
+			return false, nil
+		}
+		if server.Status == state {
+			return true, nil
+		}
+		for _, failure := range waitOpts.FailureStates {
+			if server.Status == failure {
+				return false, fmt.Errorf("vpn server %s entered failure state %s", serverId, server.Status)
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, waitError(fmt.Sprintf("wait vpn server %s to be %s failed, last status: %s", serverId, state, server.Status), err, lastErr)
+	}
+	return &server, nil
+}
+
+// WaitForAPIReady 轮询直到pritunl api可以正常访问。
+// 认证失败等客户端错误会立即返回，网络错误和5xx会继续重试。
+// 修改了会导致web服务重启的系统配置之后，旧进程退出前api仍然可以访问，应使用WaitForAPIRestart
+func WaitForAPIReady(ctx context.Context, c *Client, opts *WaitOpts) error {
+	waitOpts := opts.withDefaults()
+	ctx, cancel := context.WithTimeout(ctx, waitOpts.Timeout)
+	defer cancel()
+	client := c.WithContext(ctx)
+
+	var lastErr error
+	err := poll(ctx, waitOpts.Interval, func() (bool, error) {
+		up, unavailableErr, err := checkAPI(client)
+		lastErr = unavailableErr
+		return up, err
+	})
+	if err != nil {
+		return waitError("wait pritunl api ready failed", err, lastErr)
+	}
+	return nil
+}
+
+// WaitForAPIRestart 等待pritunl web服务完成一次重启，先轮询直到api不可访问，再轮询直到api恢复，
+// 两个阶段各自使用opts中的超时时间。重启可能在两次轮询之间完成，因此超时时间内一直没有观察到api不可访问时，
+// 视为重启已经完成，按WaitForAPIReady确认api可以访问后返回
+func WaitForAPIRestart(ctx context.Context, c *Client, opts *WaitOpts) error {
+	waitOpts := opts.withDefaults()
+	downCtx, cancel := context.WithTimeout(ctx, waitOpts.Timeout)
+	defer cancel()
+	client := c.WithContext(downCtx)
+
+	err := poll(downCtx, waitOpts.Interval, func() (bool, error) {
+		up, _, err := checkAPI(client)
+		if downCtx.Err() != nil {
+			// 请求因超时或取消失败，不代表api不可访问
+			return false, nil
+		}
+		return !up, err
+	})
+	if err != nil && !(errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil) {
+		return fmt.Errorf("wait pritunl api restart failed, err: %w", err)
+	}
+	return WaitForAPIReady(ctx, c, opts)
+}
+
+// checkAPI 请求一次api，返回api是否可以访问。网络错误和5xx视为暂时不可访问，通过unavailableErr返回；
+// 认证失败等客户端错误通过err返回，调用方应停止等待
+func checkAPI(c *Client) (up bool, unavailableErr, err error) {
+	resp, err := c.Request("get", getServerSettingsPath(), nil)
+	if err == nil {
+		return true, nil, nil
+	}
+	if resp != nil && resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return false, nil, err
+	}
+	return false, err, nil
+}
+
+// poll 按interval执行check，直到check返回true或者返回错误，或者ctx结束
+func poll(ctx context.Context, interval time.Duration, check func() (bool, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		done, err := check()
+		if err != nil || done {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitError 组装等待失败的错误，超时时带上最后一次请求的错误
+func waitError(message string, err, lastErr error) error {
+	if lastErr != nil && errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s, err: %w, last err: %v", message, err, lastErr)
+	}
+	return fmt.Errorf("%s, err: %w", message, err)
+}
@@ -0,0 +1,140 @@
+package pritunl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testWaitOpts 测试使用的轮询间隔和超时时间
+var testWaitOpts = &WaitOpts{Interval: 5 * time.Millisecond, Timeout: 2 * time.Second}
+
+func TestWaitForServerStateFailureState(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeJSON(w, VpnServer{Id: "s1", Status: ServerStatusOffline})
+	}))
+
+	opts := *testWaitOpts
+	opts.FailureStates = []string{ServerStatusOffline}
+	_, err := WaitForServerState(context.Background(), client, "s1", ServerStatusOnline, &opts)
+	if err == nil || !strings.Contains(err.Error(), "failure state offline") {
+		t.Fatalf("WaitForServerState() = %v, want failure state error", err)
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want fail on the first poll", requests)
+	}
+}
+
+// restartingAPI 模拟web服务重启，前up个请求正常响应，之后down个请求返回503，再之后恢复正常
+type restartingAPI struct {
+	mu       sync.Mutex
+	up       int
+	down     int
+	requests int
+}
+
+func (a *restartingAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests++
+	if a.requests > a.up && a.requests <= a.up+a.down {
+		http.Error(w, "restarting", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, map[string]interface{}{})
+}
+
+func TestWaitForAPIRestart(t *testing.T) {
+	api := &restartingAPI{up: 3, down: 2}
+	client := newTestClient(t, api)
+
+	if err := WaitForAPIRestart(context.Background(), client, testWaitOpts); err != nil {
+		t.Fatal(err)
+	}
+	// 旧进程响应的3个请求、重启中的2个请求以及恢复后的1个请求
+	if api.requests != 6 {
+		t.Errorf("requests = %d, want 6", api.requests)
+	}
+}
+
+func TestWaitForAPIRestartNotObserved(t *testing.T) {
+	api := &restartingAPI{up: 1 << 30}
+	client := newTestClient(t, api)
+	opts := &WaitOpts{Interval: 5 * time.Millisecond, Timeout: 50 * time.Millisecond}
+	if err := WaitForAPIRestart(context.Background(), client, opts); err != nil {
+		t.Errorf("WaitForAPIRestart() = %v, want the api confirmed ready", err)
+	}
+
+	// ctx被取消时不视为重启完成
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := WaitForAPIRestart(ctx, client, opts); !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitForAPIRestart() = %v, want context.Canceled", err)
+	}
+}
+
+func TestWaitForAPIRestartBetweenPolls(t *testing.T) {
+	var requests atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		writeJSON(w, map[string]interface{}{})
+	})
+	old := httptest.NewTLSServer(handler)
+	addr := old.Listener.Addr().String()
+	client, err := NewClient("token", "secret", addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次轮询之后，在下一次轮询之前关闭旧进程并在同一地址启动新进程
+	restarted := make(chan *httptest.Server, 1)
+	go func() {
+		for requests.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		old.Close()
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			restarted <- nil
+			return
+		}
+		srv := httptest.NewUnstartedServer(handler)
+		srv.Listener.Close()
+		srv.Listener = listener
+		srv.StartTLS()
+		restarted <- srv
+	}()
+
+	opts := &WaitOpts{Interval: 50 * time.Millisecond, Timeout: 300 * time.Millisecond}
+	err = WaitForAPIRestart(context.Background(), client, opts)
+	srv := <-restarted
+	if srv == nil {
+		t.Skip("listen on the old address failed")
+	}
+	defer srv.Close()
+	if err != nil {
+		t.Fatalf("WaitForAPIRestart() = %v, want success after a restart between polls", err)
+	}
+	if requests.Load() < 2 {
+		t.Errorf("requests = %d, want the new process polled", requests.Load())
+	}
+}
+
+func TestWaitForAPIRestartClientError(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	err := WaitForAPIRestart(context.Background(), client, testWaitOpts)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("WaitForAPIRestart() = %v, want auth error", err)
+	}
+}
@@ -0,0 +1,564 @@
+package pritunl
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+// wireguard模式。server开启wg后会在openvpn之外同时运行wireguard，wireguard使用独立的udp端口和网段。
+// 导出的用户连接配置中只有openvpn配置，wireguard配置由客户端用openvpn配置中的同步凭证向server申请，
+// 不使用pritunl客户端时，可以通过RequestWireGuardConfig申请，得到wg-quick格式的客户端配置
+
+// DefaultWireGuardPort wireguard的默认端口，已被占用时依次递增
+const DefaultWireGuardPort = 51820
+
+// EnableServerWireGuard 为server开启wireguard，port为0时自动分配未被其他server使用的端口，
+// network为空时自动分配一个不与其他server重叠的网段。需要server处于停止状态
+func EnableServerWireGuard(c *Client, serverId string, port int, network string) (*VpnServer, error) {
+	if err := c.requireFeature(FeatureWireGuard); err != nil {
+		return nil, err
+	}
+	if port == 0 || len(network) == 0 {
+		servers, err := GetServerList(c)
+		if err != nil {
+			return nil, err
+		}
+		if port == 0 {
+			port = allocateWireGuardPort(servers, serverId)
+		}
+		if len(network) == 0 {
+			if network, err = allocateWireGuardNetwork(servers); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if port < 1 || port > 65535 {
+		return nil, fmt.Errorf("wireguard端口%d错误", port)
+	}
+	if _, _, err := net.ParseCIDR(network); err != nil {
+		return nil, fmt.Errorf("wireguard网段%s格式错误", network)
+	}
+	return UpdateServer(c, serverId, ServerUpdateOpts{
+		Wg:        Bool(true),
+		PortWg:    Int(port),
+		NetworkWg: String(network),
+	})
+}
+
+// DisableServerWireGuard 关闭server的wireguard，需要server处于停止状态
+func DisableServerWireGuard(c *Client, serverId string) (*VpnServer, error) {
+	return UpdateServer(c, serverId, ServerUpdateOpts{Wg: Bool(false)})
+}
+
+// allocateWireGuardPort 从DefaultWireGuardPort开始查找未被其他server使用的端口
+func allocateWireGuardPort(servers []VpnServer, serverId string) int {
+	used := map[int]bool{}
+	for _, server := range servers {
+		used[server.Port] = true
+		if server.Id != serverId {
+			used[server.PortWg] = true
+		}
+	}
+	port := DefaultWireGuardPort
+	for used[port] {
+		port++
+	}
+	return port
+}
+
+// allocateWireGuardNetwork 在10.0.0.0/8中查找一个不与其他server的openvpn和wireguard网段重叠的/24网段
+func allocateWireGuardNetwork(servers []VpnServer) (string, error) {
+	var used []*net.IPNet
+	for _, server := range servers {
+		for _, network := range []string{server.Network, server.NetworkWg} {
+			if _, ipNet, err := net.ParseCIDR(network); err == nil {
+				used = append(used, ipNet)
+			}
+		}
+	}
+
+	for second := 100; second < 256; second++ {
+		for third := 0; third < 256; third++ {
+			_, candidate, _ := net.ParseCIDR(fmt.Sprintf("10.%d.%d.0/24", second, third))
+			overlapped := false
+			for _, ipNet := range used {
+				if ipNet.Contains(candidate.IP) || candidate.Contains(ipNet.IP) {
+					overlapped = true
+					break
+				}
+			}
+			if !overlapped {
+				return candidate.String(), nil
+			}
+		}
+	}
+	return "", errors.New("没有可用的wireguard网段")
+}
+
+// GenerateWireGuardKeyPair 生成wireguard密钥对，返回base64编码的私钥和公钥
+func GenerateWireGuardKeyPair() (privateKey, publicKey string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(key.Bytes()),
+		base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// WireGuardPublicKey 由base64编码的私钥计算公钥
+func WireGuardPublicKey(privateKey string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("decode wireguard private key failed, err: %w", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// WireGuardInterface wg-quick配置的[Interface]段
+type WireGuardInterface struct {
+	PrivateKey string
+	Address    []string // 客户端地址，cidr格式
+	DNS        []string
+	MTU        int
+	ListenPort int
+}
+
+// WireGuardPeer wg-quick配置的[Peer]段
+type WireGuardPeer struct {
+	PublicKey           string
+	PresharedKey        string
+	Endpoint            string   // server地址，host:port格式
+	AllowedIPs          []string // 经由该peer访问的网段
+	PersistentKeepalive int
+}
+
+// WireGuardConfig wg-quick格式的客户端配置，可以直接导入wireguard官方客户端
+type WireGuardConfig struct {
+	Interface WireGuardInterface
+	Peers     []WireGuardPeer
+}
+
+// ParseWireGuardConfig 解析wg-quick格式的配置，字段名不区分大小写，不认识的字段会被忽略
+func ParseWireGuardConfig(content []byte) (*WireGuardConfig, error) {
+	config := &WireGuardConfig{}
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			switch section {
+			case "interface":
+			case "peer":
+				config.Peers = append(config.Peers, WireGuardPeer{})
+			default:
+				return nil, fmt.Errorf("line %d: unknown section %s", lineNo, line)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid line %q", lineNo, line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		var err error
+		switch section {
+		case "interface":
+			err = config.Interface.set(key, value)
+		case "peer":
+			err = config.Peers[len(config.Peers)-1].set(key, value)
+		default:
+			err = errors.New("field outside of section")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// set 设置[Interface]段的字段
+func (i *WireGuardInterface) set(key, value string) error {
+	var err error
+	switch key {
+	case "privatekey":
+		i.PrivateKey = value
+	case "address":
+		i.Address = append(i.Address, splitList(value)...)
+	case "dns":
+		i.DNS = append(i.DNS, splitList(value)...)
+	case "mtu":
+		i.MTU, err = strconv.Atoi(value)
+	case "listenport":
+		i.ListenPort, err = strconv.Atoi(value)
+	}
+	return err
+}
+
+// set 设置[Peer]段的字段
+func (p *WireGuardPeer) set(key, value string) error {
+	var err error
+	switch key {
+	case "publickey":
+		p.PublicKey = value
+	case "presharedkey":
+		p.PresharedKey = value
+	case "endpoint":
+		p.Endpoint = value
+	case "allowedips":
+		p.AllowedIPs = append(p.AllowedIPs, splitList(value)...)
+	case "persistentkeepalive":
+		p.PersistentKeepalive, err = strconv.Atoi(value)
+	}
+	return err
+}
+
+// splitList 解析逗号分隔的列表
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Marshal 输出wg-quick格式的配置
+func (w *WireGuardConfig) Marshal() []byte {
+	buf := bytes.Buffer{}
+	write := func(key, value string) {
+		if len(value) > 0 && value != "0" {
+			fmt.Fprintf(&buf, "%s = %s\n", key, value)
+		}
+	}
+
+	buf.WriteString("[Interface]\n")
+	write("PrivateKey", w.Interface.PrivateKey)
+	write("Address", strings.Join(w.Interface.Address, ", "))
+	write("DNS", strings.Join(w.Interface.DNS, ", "))
+	write("MTU", strconv.Itoa(w.Interface.MTU))
+	write("ListenPort", strconv.Itoa(w.Interface.ListenPort))
+	for _, peer := range w.Peers {
+		buf.WriteString("\n[Peer]\n")
+		write("PublicKey", peer.PublicKey)
+		write("PresharedKey", peer.PresharedKey)
+		write("Endpoint", peer.Endpoint)
+		write("AllowedIPs", strings.Join(peer.AllowedIPs, ", "))
+		write("PersistentKeepalive", strconv.Itoa(peer.PersistentKeepalive))
+	}
+	return buf.Bytes()
+}
+
+// ConnectionFile 将配置转换为连接配置文件，name不带后缀
+func (w *WireGuardConfig) ConnectionFile(name string) ConnectionFile {
+	return ConnectionFile{Name: name + ".conf", Content: w.Marshal()}
+}
+
+// ProfileHeader pritunl在openvpn连接配置文件开头以注释形式写入的json，包含配置同步和wireguard密钥交换需要的信息
+type ProfileHeader struct {
+	User               string   `json:"user"`
+	UserId             string   `json:"user_id"`
+	Organization       string   `json:"organization"`
+	OrganizationId     string   `json:"organization_id"`
+	Server             string   `json:"server"`
+	ServerId           string   `json:"server_id"`
+	SyncHosts          []string `json:"sync_hosts"`
+	SyncToken          string   `json:"sync_token"`
+	SyncSecret         string   `json:"sync_secret"`
+	ServerBoxPublicKey string   `json:"server_box_public_key"` // server的nacl box公钥，base64编码
+	Wg                 bool     `json:"wg"`                    // server是否开启了wireguard
+	Remotes            []string `json:"-"`                     // 配置中remote指令的地址，host:port格式
+}
+
+// ParseProfileHeader 解析openvpn连接配置文件开头的json注释，以及配置中的remote指令
+func ParseProfileHeader(content []byte) (*ProfileHeader, error) {
+	jsonBuf := bytes.Buffer{}
+	header := &ProfileHeader{}
+	inHeader := true
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if inHeader && strings.HasPrefix(line, "#") {
+			jsonBuf.WriteString(strings.TrimPrefix(line, "#"))
+			jsonBuf.WriteByte('\n')
+			continue
+		}
+		inHeader = false
+		if fields := strings.Fields(line); len(fields) >= 3 && fields[0] == "remote" {
+			header.Remotes = append(header.Remotes, net.JoinHostPort(fields[1], fields[2]))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if jsonBuf.Len() == 0 {
+		return nil, errors.New("连接配置中没有pritunl写入的json注释")
+	}
+	if err := json.Unmarshal(jsonBuf.Bytes(), header); err != nil {
+		return nil, fmt.Errorf("parse profile header failed, err: %w", err)
+	}
+	return header, nil
+}
+
+// wireGuardKeyRequest wireguard密钥交换的请求体，data为nacl box加密后的wireGuardKeyBox
+type wireGuardKeyRequest struct {
+	Data      string `json:"data"`
+	Nonce     string `json:"nonce"`
+	PublicKey string `json:"public_key"`
+}
+
+// wireGuardKeyBox 加密前的密钥交换内容
+type wireGuardKeyBox struct {
+	DeviceId    string `json:"device_id"`
+	DeviceName  string `json:"device_name"`
+	Platform    string `json:"platform"`
+	Nonce       string `json:"nonce"`
+	Timestamp   int64  `json:"timestamp"`
+	WgPublicKey string `json:"wg_public_key"`
+}
+
+// wireGuardKeyResponse 密钥交换的响应体，data为nacl box加密后的wireGuardKeyData，signature为data&nonce的HMAC-SHA512
+type wireGuardKeyResponse struct {
+	Data      string `json:"data"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// wireGuardKeyData 解密后的密钥交换结果
+type wireGuardKeyData struct {
+	Allow         bool                 `json:"allow"`
+	Reason        string               `json:"reason"`
+	Configuration *wireGuardServerConf `json:"configuration"`
+}
+
+// wireGuardServerConf server下发的wireguard配置
+type wireGuardServerConf struct {
+	Address      string           `json:"address"`
+	Address6     string           `json:"address6"`
+	Hostname     string           `json:"hostname"`
+	Hostname6    string           `json:"hostname6"`
+	Port         int              `json:"port"`
+	PublicKey    string           `json:"public_key"`
+	Routes       []wireGuardRoute `json:"routes"`
+	Routes6      []wireGuardRoute `json:"routes6"`
+	DnsServers   []string         `json:"dns_servers"`
+	SearchDomain string           `json:"search_domain"`
+}
+
+// wireGuardRoute server下发的路由
+type wireGuardRoute struct {
+	Network string `json:"network"`
+}
+
+// DefaultWireGuardKeepalive 生成的wireguard配置中的PersistentKeepalive，单位秒
+const DefaultWireGuardKeepalive = 25
+
+// RequestWireGuardConfig 使用openvpn连接配置中的同步凭证，通过server的wireguard密钥交换接口为该连接生成wireguard配置。
+// 本地生成wireguard密钥对，公钥经nacl box加密后提交，server返回分配的地址、server公钥和路由。
+// 请求使用连接配置中的sync token签名，不使用客户端的api认证信息，server未开启wireguard时返回错误
+func RequestWireGuardConfig(c *Client, profile ConnectionFile) (*WireGuardConfig, error) {
+	header, err := ParseProfileHeader(profile.Content)
+	if err != nil {
+		return nil, err
+	}
+	if !header.Wg {
+		return nil, fmt.Errorf("server %s没有开启wireguard", header.Server)
+	}
+	if len(header.SyncToken) == 0 || len(header.SyncSecret) == 0 || len(header.ServerBoxPublicKey) == 0 {
+		return nil, errors.New("连接配置中缺少wireguard密钥交换需要的同步凭证")
+	}
+	serverBoxKey, err := decodeBoxKey(header.ServerBoxPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode server box public key failed, err: %w", err)
+	}
+
+	wgPrivateKey, wgPublicKey, err := GenerateWireGuardKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	boxPublicKey, boxPrivateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := json.Marshal(wireGuardKeyBox{
+		DeviceId:    generateNonce(),
+		DeviceName:  "pritunl-client-go",
+		Platform:    "linux",
+		Nonce:       generateNonce(),
+		Timestamp:   c.clock.now().Unix(),
+		WgPublicKey: wgPublicKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var boxNonce [24]byte
+	if _, err = rand.Read(boxNonce[:]); err != nil {
+		return nil, err
+	}
+	keyReq := wireGuardKeyRequest{
+		Data:      base64.StdEncoding.EncodeToString(box.Seal(nil, plain, &boxNonce, serverBoxKey, boxPrivateKey)),
+		Nonce:     base64.StdEncoding.EncodeToString(boxNonce[:]),
+		PublicKey: base64.StdEncoding.EncodeToString(boxPublicKey[:]),
+	}
+
+	// 签名在标准的token&timestamp&nonce&METHOD&path之后追加加密数据、box随机串和box公钥，使用sync secret做HMAC-SHA512
+	path := getWireGuardKeyUrl(header.OrganizationId, header.UserId, header.ServerId)
+	timestamp := strconv.FormatInt(c.clock.now().Unix(), 10)
+	authNonce := c.clock.nonce()
+	authString := strings.Join([]string{
+		AuthString(header.SyncToken, timestamp, authNonce, "POST", path),
+		keyReq.Data, keyReq.Nonce, keyReq.PublicKey,
+	}, "&")
+	var keyResp wireGuardKeyResponse
+	opts := RequestOpts{
+		JSONBody:     keyReq,
+		JSONResponse: &keyResp,
+		MoreHeaders: map[string]string{
+			"Auth-Token":     header.SyncToken,
+			"Auth-Timestamp": timestamp,
+			"Auth-Nonce":     authNonce,
+			"Auth-Signature": hmacSHA512Base64(header.SyncSecret, authString),
+		},
+	}
+	if _, err = c.doRequest("POST", c.serverUrl(path), &opts); err != nil {
+		return nil, fmt.Errorf("wireguard key exchange failed, err: %w", err)
+	}
+
+	if !hmac.Equal([]byte(keyResp.Signature), []byte(hmacSHA512Base64(header.SyncSecret, keyResp.Data+"&"+keyResp.Nonce))) {
+		return nil, errors.New("wireguard key exchange response signature invalid")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(keyResp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decode wireguard key exchange response failed, err: %w", err)
+	}
+	respNonce, err := base64.StdEncoding.DecodeString(keyResp.Nonce)
+	if err != nil || len(respNonce) != 24 {
+		return nil, errors.New("wireguard key exchange response nonce invalid")
+	}
+	copy(boxNonce[:], respNonce)
+	opened, ok := box.Open(nil, sealed, &boxNonce, serverBoxKey, boxPrivateKey)
+	if !ok {
+		return nil, errors.New("decrypt wireguard key exchange response failed")
+	}
+	var keyData wireGuardKeyData
+	if err = json.Unmarshal(opened, &keyData); err != nil {
+		return nil, fmt.Errorf("parse wireguard key exchange response failed, err: %w", err)
+	}
+	if !keyData.Allow || keyData.Configuration == nil {
+		return nil, fmt.Errorf("server拒绝了wireguard连接: %s", keyData.Reason)
+	}
+	return buildWireGuardConfig(header, wgPrivateKey, keyData.Configuration)
+}
+
+// buildWireGuardConfig 由server下发的配置构造wg-quick格式的客户端配置，server没有返回地址时使用连接配置中的remote地址
+func buildWireGuardConfig(header *ProfileHeader, privateKey string, conf *wireGuardServerConf) (*WireGuardConfig, error) {
+	host := conf.Hostname
+	if len(host) == 0 && len(header.Remotes) > 0 {
+		host, _, _ = net.SplitHostPort(header.Remotes[0])
+	}
+	if len(host) == 0 || conf.Port == 0 {
+		return nil, errors.New("server没有返回wireguard的地址和端口")
+	}
+	config := &WireGuardConfig{
+		Interface: WireGuardInterface{
+			PrivateKey: privateKey,
+			DNS:        conf.DnsServers,
+		},
+		Peers: []WireGuardPeer{{
+			PublicKey:           conf.PublicKey,
+			Endpoint:            net.JoinHostPort(host, strconv.Itoa(conf.Port)),
+			PersistentKeepalive: DefaultWireGuardKeepalive,
+		}},
+	}
+	for _, address := range []string{conf.Address, conf.Address6} {
+		if len(address) > 0 {
+			config.Interface.Address = append(config.Interface.Address, address)
+		}
+	}
+	for _, route := range append(conf.Routes, conf.Routes6...) {
+		config.Peers[0].AllowedIPs = append(config.Peers[0].AllowedIPs, route.Network)
+	}
+	return config, nil
+}
+
+// decodeBoxKey 解码base64编码的nacl box公钥
+func decodeBoxKey(key string) (*[32]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("invalid key length %d", len(raw))
+	}
+	var boxKey [32]byte
+	copy(boxKey[:], raw)
+	return &boxKey, nil
+}
+
+// hmacSHA512Base64 计算base64编码的HMAC-SHA512
+func hmacSHA512Base64(secret, message string) string {
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ExportUserWireGuardConfigs 导出用户的连接配置，对其中开启了wireguard的server逐个进行密钥交换，返回wireguard配置。
+// 导出的压缩包中只有openvpn配置，wireguard配置需要通过RequestWireGuardConfig向server申请，没有开启wireguard的server会被跳过
+func ExportUserWireGuardConfigs(c *Client, organizationId, userId string) ([]WireGuardConfig, error) {
+	files, err := ExportUserConnectFiles(c, organizationId, userId)
+	if err != nil {
+		return nil, err
+	}
+	var configs []WireGuardConfig
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name, ".ovpn") {
+			continue
+		}
+		header, err := ParseProfileHeader(file.Content)
+		if err != nil {
+			return nil, fmt.Errorf("parse profile %s failed, err: %w", file.Name, err)
+		}
+		if !header.Wg {
+			continue
+		}
+		config, err := RequestWireGuardConfig(c, file)
+		if err != nil {
+			return nil, fmt.Errorf("request wireguard config of %s failed, err: %w", file.Name, err)
+		}
+		configs = append(configs, *config)
+	}
+	return configs, nil
+}
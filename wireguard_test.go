@@ -0,0 +1,186 @@
+package pritunl
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+// fakeWireGuardServer 用户导出和wireguard密钥交换接口的替身，按pritunl的方式在openvpn配置开头写入json注释
+type fakeWireGuardServer struct {
+	t          *testing.T
+	publicKey  *[32]byte
+	privateKey *[32]byte
+	profiles   map[string]string
+	exchanged  []wireGuardKeyBox // 收到的密钥交换内容
+}
+
+func newFakeWireGuardServer(t *testing.T) *fakeWireGuardServer {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeWireGuardServer{t: t, publicKey: publicKey, privateKey: privateKey}
+	f.profiles = map[string]string{
+		"dev_alice_office.ovpn": f.profile("s1", "office", true),
+		"dev_alice_lab.ovpn":    f.profile("s2", "lab", false),
+	}
+	return f
+}
+
+// profile 生成带pritunl json注释头的openvpn配置，注释头与server导出的一样按行拆开
+func (f *fakeWireGuardServer) profile(serverId, serverName string, wg bool) string {
+	header, err := json.MarshalIndent(map[string]interface{}{
+		"user": "alice", "user_id": "u1", "organization": "dev", "organization_id": "org1",
+		"server": serverName, "server_id": serverId, "sync_hosts": []string{"https://vpn.example.com"},
+		"sync_token": "sync-token", "sync_secret": "sync-secret",
+		"server_box_public_key": base64.StdEncoding.EncodeToString(f.publicKey[:]), "wg": wg,
+	}, "", "  ")
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return "#" + strings.ReplaceAll(string(header), "\n", "\n#") + "\nclient\ndev tun\nremote vpn.example.com 1194 udp\n"
+}
+
+func (f *fakeWireGuardServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/key/org1/u1.tar":
+		_, _ = w.Write(tarball(f.t, f.profiles))
+	case strings.HasPrefix(r.URL.Path, "/key/wg/"):
+		f.exchange(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeWireGuardServer) exchange(w http.ResponseWriter, r *http.Request) {
+	var req wireGuardKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	authString := strings.Join([]string{
+		AuthString(r.Header.Get("Auth-Token"), r.Header.Get("Auth-Timestamp"), r.Header.Get("Auth-Nonce"), r.Method, r.URL.Path),
+		req.Data, req.Nonce, req.PublicKey,
+	}, "&")
+	if r.Header.Get("Auth-Token") != "sync-token" || r.Header.Get("Auth-Signature") != hmacSHA512Base64("sync-secret", authString) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	clientKey, err := decodeBoxKey(req.PublicKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sealed, _ := base64.StdEncoding.DecodeString(req.Data)
+	rawNonce, _ := base64.StdEncoding.DecodeString(req.Nonce)
+	var nonce [24]byte
+	copy(nonce[:], rawNonce)
+	plain, ok := box.Open(nil, sealed, &nonce, clientKey, f.privateKey)
+	if !ok {
+		http.Error(w, "decrypt failed", http.StatusBadRequest)
+		return
+	}
+	var keyBox wireGuardKeyBox
+	if err = json.Unmarshal(plain, &keyBox); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.exchanged = append(f.exchanged, keyBox)
+
+	data, _ := json.Marshal(wireGuardKeyData{Allow: true, Configuration: &wireGuardServerConf{
+		Address:    "10.20.0.5/24",
+		Address6:   "fd00:20::5/64",
+		Port:       51820,
+		PublicKey:  "c2VydmVyLXdnLXB1YmxpYy1rZXktMzItYnl0ZXMhISE=",
+		Routes:     []wireGuardRoute{{Network: "10.20.0.0/24"}, {Network: "192.168.1.0/24"}},
+		Routes6:    []wireGuardRoute{{Network: "fd00:20::/64"}},
+		DnsServers: []string{"10.20.0.1"},
+	}})
+	if _, err = rand.Read(nonce[:]); err != nil {
+		f.t.Fatal(err)
+	}
+	resp := wireGuardKeyResponse{
+		Data:  base64.StdEncoding.EncodeToString(box.Seal(nil, data, &nonce, clientKey, f.privateKey)),
+		Nonce: base64.StdEncoding.EncodeToString(nonce[:]),
+	}
+	resp.Signature = hmacSHA512Base64("sync-secret", resp.Data+"&"+resp.Nonce)
+	writeJSON(w, resp)
+}
+
+func TestParseProfileHeader(t *testing.T) {
+	fake := newFakeWireGuardServer(t)
+	header, err := ParseProfileHeader([]byte(fake.profiles["dev_alice_office.ovpn"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.ServerId != "s1" || header.UserId != "u1" || header.OrganizationId != "org1" || !header.Wg ||
+		header.SyncToken != "sync-token" || fmt.Sprint(header.Remotes) != "[vpn.example.com:1194]" {
+		t.Errorf("unexpected header: %+v", header)
+	}
+
+	// 单行注释头
+	if header, err = ParseProfileHeader([]byte("#{\"server_id\": \"s3\", \"wg\": true}\nclient\n")); err != nil || header.ServerId != "s3" {
+		t.Errorf("ParseProfileHeader() = %+v, %v", header, err)
+	}
+	if _, err = ParseProfileHeader([]byte("client\nremote vpn.example.com 1194 udp\n")); err == nil {
+		t.Error("profile without header should fail")
+	}
+}
+
+func TestExportUserWireGuardConfigs(t *testing.T) {
+	fake := newFakeWireGuardServer(t)
+	client := newTestClient(t, fake)
+
+	configs, err := ExportUserWireGuardConfigs(client, "org1", "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 || len(fake.exchanged) != 1 {
+		t.Fatalf("configs = %+v, exchanged = %+v, want only the wireguard server", configs, fake.exchanged)
+	}
+
+	config := configs[0]
+	privateKey, err := base64.StdEncoding.DecodeString(config.Interface.PrivateKey)
+	if err != nil || len(privateKey) != 32 {
+		t.Errorf("invalid private key %q", config.Interface.PrivateKey)
+	}
+	if len(fake.exchanged[0].WgPublicKey) == 0 || fake.exchanged[0].WgPublicKey == config.Interface.PrivateKey {
+		t.Errorf("server should receive the public key only: %+v", fake.exchanged[0])
+	}
+	if fmt.Sprint(config.Interface.Address) != "[10.20.0.5/24 fd00:20::5/64]" || fmt.Sprint(config.Interface.DNS) != "[10.20.0.1]" {
+		t.Errorf("unexpected interface: %+v", config.Interface)
+	}
+	peer := config.Peers[0]
+	if peer.Endpoint != "vpn.example.com:51820" || peer.PersistentKeepalive != DefaultWireGuardKeepalive ||
+		fmt.Sprint(peer.AllowedIPs) != "[10.20.0.0/24 192.168.1.0/24 fd00:20::/64]" {
+		t.Errorf("unexpected peer: %+v", peer)
+	}
+
+	// 生成的配置可以按wg-quick格式输出后再解析回来
+	parsed, err := ParseWireGuardConfig(config.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Peers[0].Endpoint != peer.Endpoint {
+		t.Errorf("round trip endpoint = %s", parsed.Peers[0].Endpoint)
+	}
+}
+
+func TestRequestWireGuardConfigDisabled(t *testing.T) {
+	fake := newFakeWireGuardServer(t)
+	client := newTestClient(t, fake)
+	profile := ConnectionFile{Name: "dev_alice_lab.ovpn", Content: []byte(fake.profiles["dev_alice_lab.ovpn"])}
+	if _, err := RequestWireGuardConfig(client, profile); err == nil {
+		t.Error("server without wireguard should fail")
+	}
+	if len(fake.exchanged) != 0 {
+		t.Errorf("no key exchange expected: %+v", fake.exchanged)
+	}
+}